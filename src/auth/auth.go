@@ -0,0 +1,116 @@
+// Package auth authenticates requests by API keys.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/model"
+)
+
+const (
+	// keyHeader is an alternative to Authorization: Bearer header.
+	keyHeader = "X-API-Key"
+	// keyPrefix makes keys easy to recognize, e.g. by secret scanners.
+	keyPrefix = "imgr_"
+)
+
+type contextKey struct{}
+
+// Service authenticates requests.
+type Service struct {
+	repo model.APIKeysRepository
+}
+
+// New creates new Service with keys repository.
+func New(repo model.APIKeysRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// Authenticate is a middleware which rejects requests without valid API key,
+// key of authenticated request and its tenant are attached to its context.
+func (s *Service) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := keyFromRequest(r)
+		if secret == "" {
+			unauthorized(w, r, "API key is required")
+			return
+		}
+		key, err := s.repo.ByHash(r.Context(), HashKey(secret))
+		if errors.Is(err, model.ErrNotFound) {
+			unauthorized(w, r, "API key is invalid or revoked")
+			return
+		}
+		if err != nil {
+			data, statusCode := apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, "error authenticating request", err)
+			apierror.Write(w, data, statusCode)
+			return
+		}
+		ctx := model.WithTenant(WithKey(r.Context(), key), key.TenantID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireScope wraps handler so it's only called for keys with scope.
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := KeyFromContext(r.Context())
+		if !ok {
+			unauthorized(w, r, "API key is required")
+			return
+		}
+		if !key.HasScope(scope) {
+			data, statusCode := apierror.New(w, r, http.StatusForbidden, apierror.CodeForbidden,
+				fmt.Sprintf("API key '%s' doesn't have scope '%s'", key.Name, scope), nil)
+			apierror.Write(w, data, statusCode)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// WithKey returns copy of ctx with authenticated key.
+func WithKey(ctx context.Context, key model.APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// KeyFromContext returns key request was authenticated with.
+func KeyFromContext(ctx context.Context) (model.APIKey, bool) {
+	key, ok := ctx.Value(contextKey{}).(model.APIKey)
+	return key, ok
+}
+
+// GenerateKey returns new random key secret.
+func GenerateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return keyPrefix + hex.EncodeToString(b), nil
+}
+
+// HashKey returns hex encoded sha256 hash of key secret, only hashes are stored.
+func HashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func keyFromRequest(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	return r.Header.Get(keyHeader)
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	data, statusCode := apierror.New(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, message, nil)
+	apierror.Write(w, data, statusCode)
+}
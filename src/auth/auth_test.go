@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_model "github.com/imager/src/mock/model"
+	"github.com/imager/src/model"
+)
+
+func TestAuthenticate(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	const secret = "imgr_test"
+	key := model.APIKey{ID: 1, Name: "test", TenantID: "team-a", Scopes: []string{model.ScopeImagesRead}}
+
+	type tc struct {
+		name               string
+		header             http.Header
+		getTest            func() *Service
+		expectedStatusCode int
+	}
+
+	tcs := []tc{
+		{
+			name: "http.StatusUnauthorized: no key",
+			getTest: func() *Service {
+				return New(nil)
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "http.StatusUnauthorized: unknown key",
+			header: http.Header{"Authorization": {"Bearer " + secret}},
+			getTest: func() *Service {
+				keysRepo := mock_model.NewMockAPIKeysRepository(mockCtrl)
+				keysRepo.EXPECT().ByHash(gomock.Any(), HashKey(secret)).Return(model.APIKey{}, fmt.Errorf("test: %w", model.ErrNotFound))
+				return New(keysRepo)
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "http.StatusInternalServerError",
+			header: http.Header{"Authorization": {"Bearer " + secret}},
+			getTest: func() *Service {
+				keysRepo := mock_model.NewMockAPIKeysRepository(mockCtrl)
+				keysRepo.EXPECT().ByHash(gomock.Any(), HashKey(secret)).Return(model.APIKey{}, errors.New("error"))
+				return New(keysRepo)
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:   "http.StatusOK: bearer token",
+			header: http.Header{"Authorization": {"Bearer " + secret}},
+			getTest: func() *Service {
+				keysRepo := mock_model.NewMockAPIKeysRepository(mockCtrl)
+				keysRepo.EXPECT().ByHash(gomock.Any(), HashKey(secret)).Return(key, nil)
+				return New(keysRepo)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "http.StatusOK: key header",
+			header: http.Header{http.CanonicalHeaderKey(keyHeader): {secret}},
+			getTest: func() *Service {
+				keysRepo := mock_model.NewMockAPIKeysRepository(mockCtrl)
+				keysRepo.EXPECT().ByHash(gomock.Any(), HashKey(secret)).Return(key, nil)
+				return New(keysRepo)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/images", nil)
+			for k := range tc.header {
+				r.Header.Set(k, tc.header.Get(k))
+			}
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got, ok := KeyFromContext(r.Context()); !ok || got.ID != key.ID {
+					t.Fatalf("expected key %d in context, got: %v", key.ID, got)
+				}
+				if tenantID := model.TenantFromContext(r.Context()); tenantID != key.TenantID {
+					t.Fatalf("expected tenant is: %s but got: %s", key.TenantID, tenantID)
+				}
+			})
+			tc.getTest().Authenticate(next).ServeHTTP(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	type tc struct {
+		name               string
+		key                *model.APIKey
+		expectedStatusCode int
+	}
+
+	tcs := []tc{
+		{
+			name:               "http.StatusUnauthorized: not authenticated",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "http.StatusForbidden: missing scope",
+			key:                &model.APIKey{Scopes: []string{model.ScopeImagesRead}},
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "http.StatusOK: scope",
+			key:                &model.APIKey{Scopes: []string{model.ScopeImagesRead, model.ScopeImagesWrite}},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "http.StatusOK: admin",
+			key:                &model.APIKey{Scopes: []string{model.ScopeAdmin}},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/images", nil)
+			if tc.key != nil {
+				r = r.WithContext(WithKey(r.Context(), *tc.key))
+			}
+			RequireScope(model.ScopeImagesWrite, func(w http.ResponseWriter, r *http.Request) {})(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Command apikey issues API key directly in DB, it's used to create the first admin key.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/imager/src/auth"
+	"github.com/imager/src/config"
+	"github.com/imager/src/model"
+	"github.com/imager/src/repository/keys"
+	"github.com/imager/src/repository/postgres"
+)
+
+// main takes db settings from the same config, environment and flags as the service.
+func main() {
+	fs := flag.NewFlagSet("apikey", flag.ContinueOnError)
+	name := fs.String("name", "admin", "name of the key")
+	tenantID := fs.String("tenant", model.DefaultTenant, "tenant the key acts on behalf of")
+	scopes := fs.String("scopes", model.ScopeAdmin, "comma separated scopes of the key")
+	cfg, err := config.LoadFlagSet(fs, os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("error loading config: %v\n", err)
+	}
+	if !model.ValidTenantID(*tenantID) {
+		log.Fatalf("tenant id '%s' should consist of 1 to 64 lowercase letters, digits and dashes\n", *tenantID)
+	}
+	var keyScopes []string
+	for _, scope := range strings.Split(*scopes, ",") {
+		keyScopes = append(keyScopes, strings.TrimSpace(scope))
+	}
+	if err := model.ValidateScopes(keyScopes); err != nil {
+		log.Fatalf("error validating scopes: %v\n", err)
+	}
+
+	db, err := postgres.Open(cfg.DB)
+	if err != nil {
+		log.Fatalf("error creating db connection: %v\n", err)
+	}
+	defer db.Close()
+
+	secret, err := auth.GenerateKey()
+	if err != nil {
+		log.Fatalf("error generating key: %v\n", err)
+	}
+
+	key, err := keys.NewRepo(db).Create(context.Background(), model.APIKey{
+		Name:     *name,
+		TenantID: *tenantID,
+		Scopes:   keyScopes,
+	}, auth.HashKey(secret))
+	if err != nil {
+		log.Fatalf("error saving key: %v\n", err)
+	}
+
+	log.Printf("key %d '%s' of tenant '%s' issued with scopes: %s\n", key.ID, key.Name, key.TenantID, strings.Join(key.Scopes, ", "))
+	fmt.Println(secret)
+}
@@ -0,0 +1,90 @@
+// Command backfill generates blurhash, lqip and perceptual hash for originals stored before they were introduced.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/disintegration/imaging"
+	"github.com/imager/src/config"
+	"github.com/imager/src/imgproc/phash"
+	"github.com/imager/src/imgproc/placeholder"
+	"github.com/imager/src/model"
+	"github.com/imager/src/repository/images"
+	"github.com/imager/src/repository/postgres"
+	"github.com/imager/src/web/downloader"
+)
+
+// main takes db and storage settings from the same config, environment and flags as the service.
+func main() {
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("error loading config: %v\n", err)
+	}
+
+	db, err := postgres.Open(cfg.DB)
+	if err != nil {
+		log.Fatalf("error creating db connection: %v\n", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := images.NewRepo(db)
+	downloadSvc := downloader.New(cfg.Downloader.Options(cfg.Limits.MaxBodyBytes)...)
+
+	originals, err := repo.OriginalsToBackfill(ctx)
+	if err != nil {
+		log.Fatalf("error getting originals: %v\n", err)
+	}
+
+	var failed int
+	for _, original := range originals {
+		if err := backfill(ctx, repo, downloadSvc, original); err != nil {
+			log.Printf("error backfilling image %d: %v\n", original.ID, err)
+			failed++
+			continue
+		}
+		log.Printf("image %d backfilled\n", original.ID)
+	}
+
+	log.Printf("backfilled %d of %d images\n", len(originals)-failed, len(originals))
+	if failed != 0 {
+		os.Exit(1)
+	}
+}
+
+func backfill(ctx context.Context, repo model.ImagesRepository, downloadSvc downloader.Service, original model.Image) error {
+	b, err := downloadSvc.Download(ctx, original.DownloadURL)
+	if err != nil {
+		return err
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("error decoding image: %v", err)
+	}
+
+	if original.BlurHash == "" {
+		p, err := placeholder.Generate(img)
+		if err != nil {
+			return err
+		}
+		if err := repo.SetPlaceholder(ctx, original.ID, p.BlurHash, p.LQIP); err != nil {
+			return err
+		}
+	}
+	if original.PerceptualHash == "" {
+		if err := repo.SetPerceptualHash(ctx, original.ID, fmt.Sprintf("%016x", phash.DHash(img))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
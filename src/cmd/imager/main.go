@@ -1,76 +1,231 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-
-	_ "github.com/lib/pq"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/imager/src/config"
+	health "github.com/imager/src/handler/health"
+	handler "github.com/imager/src/handler/v1/images"
+	"github.com/imager/src/jobs"
+	"github.com/imager/src/logging"
+	"github.com/imager/src/metrics"
+	"github.com/imager/src/model"
+	"github.com/imager/src/pool"
+	eventsrepo "github.com/imager/src/repository/events"
 	"github.com/imager/src/repository/images"
+	jobsrepo "github.com/imager/src/repository/jobs"
+	"github.com/imager/src/repository/keys"
+	"github.com/imager/src/repository/postgres"
+	"github.com/imager/src/repository/quotas"
+	webhooksrepo "github.com/imager/src/repository/webhooks"
 	"github.com/imager/src/router"
 	"github.com/imager/src/web/downloader"
 	"github.com/imager/src/web/uploader"
+	"github.com/imager/src/webhooks"
 )
 
 func main() {
-	db, err := createDBsession()
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("error loading config: %v\n", err)
+	}
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level)
 	if err != nil {
-		log.Fatalf("error creating db connection: %v\n", err)
+		log.Fatalf("error creating logger: %v\n", err)
 	}
-	defer db.Close()
+	slog.SetDefault(logger)
+	slog.Info("effective config", "config", cfg.String())
+
+	if err := run(cfg); err != nil {
+		slog.Error("imager failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run serves requests until SIGTERM or SIGINT, then drains in-flight requests and background workers
+// within the shutdown timeout and releases the db pool.
+func run(cfg config.Config) error {
+	db, err := postgres.Open(cfg.DB)
+	if err != nil {
+		return fmt.Errorf("error creating db connection: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			slog.Error("error closing db", "error", err)
+		}
+	}()
 
 	session, err := session.NewSession()
 	if err != nil {
-		log.Fatalf("error creating aws session: %v\n", err)
+		return fmt.Errorf("error creating aws session: %v", err)
 	}
 
-	bucketName, err := createBucket(session)
+	bucketName, err := createBucket(session, cfg.Storage.Bucket)
 	if err != nil {
-		log.Fatalf("creating bucket '%s' failed with error :%v\n", *bucketName, err)
+		return fmt.Errorf("creating bucket '%s' failed with error :%v", *bucketName, err)
 	}
 
 	s3uploader := s3manager.NewUploader(session)
 
-	if err := http.ListenAndServe(":8080", router.New(images.NewRepo(db), uploader.New(s3uploader, bucketName), downloader.New())); err != nil {
-		log.Fatalf("error running server: %v\n", err)
+	processingPool := pool.New(cfg.Pool.Workers, cfg.Pool.QueueDepth)
+	defer processingPool.Close()
+	metrics.RegisterPool(processingPool)
+
+	imgRepo := images.NewRepo(db)
+	quotasRepo := quotas.NewRepo(db)
+	jobsRepo := jobsrepo.NewRepo(db)
+	webhooksRepo := webhooksrepo.NewRepo(db)
+	eventsRepo := eventsrepo.NewRepo(db)
+	transactor := postgres.NewTransactor(db)
+	uploadSvc := metrics.Uploader(uploader.New(s3uploader, bucketName), cfg.Storage.Backend)
+	limits := cfg.Limits
+	// stored originals are downloaded again for resizes, so they're allowed to be as large as uploads.
+	downloadSvc := metrics.Downloader(downloader.New(cfg.Downloader.Options(limits.MaxBodyBytes)...), "http")
+
+	processor := handler.NewService(
+		imgRepo,
+		uploadSvc,
+		downloadSvc,
+		handler.WithLimits(limits),
+		handler.WithQuotas(quotasRepo),
+		handler.WithPool(processingPool),
+		handler.WithWebhooks(webhooksRepo),
+		handler.WithEvents(eventsRepo),
+		handler.WithTransactor(transactor),
+	)
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	workers.Add(3)
+	go func() {
+		defer workers.Done()
+		jobs.NewWorker(jobsRepo, processor).Run(workersCtx, cfg.Jobs.Workers)
+	}()
+	go func() {
+		defer workers.Done()
+		webhooks.NewDispatcher(webhooksRepo).Run(workersCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		pruneEvents(workersCtx, eventsRepo, time.Duration(cfg.Events.Retention))
+	}()
+
+	streamsDone := make(chan struct{})
+	r := router.New(
+		imgRepo,
+		keys.NewRepo(db),
+		uploadSvc,
+		downloadSvc,
+		router.WithRateLimit(cfg.RateLimit.Rate, cfg.RateLimit.Burst),
+		router.WithIPRateLimit(cfg.RateLimit.IPRate, cfg.RateLimit.IPBurst),
+		router.WithLimits(limits),
+		router.WithQuotas(quotasRepo),
+		router.WithPool(processingPool),
+		router.WithJobs(jobsRepo),
+		router.WithWebhooks(webhooksRepo),
+		router.WithEvents(eventsRepo),
+		router.WithTransactor(transactor),
+		router.WithWriteTimeout(time.Duration(cfg.Server.WriteTimeout)),
+		router.WithShutdown(streamsDone),
+		router.WithReadinessCheck("db", db.PingContext),
+		router.WithReadinessCheck("storage", checkBucket(s3.New(session), bucketName)),
+	)
+
+	srv := &http.Server{
+		Addr: cfg.ListenAddr,
+		// middlewares wrap the whole router, so unmatched requests are logged and counted too.
+		Handler:           logging.Middleware(metrics.Middleware(r)),
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	// event streams never become idle, so they're ended for Shutdown to finish.
+	srv.RegisterOnShutdown(func() { close(streamsDone) })
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("error running server: %v", err)
+	case sig := <-signals:
+		slog.Info("shutting down", "signal", sig.String())
 	}
-}
 
-func createDBsession() (*sql.DB, error) {
-	host := os.Getenv("PGHOST")
-	port := os.Getenv("PGPORT")
-	user := os.Getenv("PGUSER")
-	password := os.Getenv("PGPASSWORD")
-	dbname := os.Getenv("PGDBNAME")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s "+
-		"password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("error draining requests", "error", err)
+	}
 
-	return sql.Open("postgres", psqlInfo)
+	stopWorkers()
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-ctx.Done():
+		slog.Error("background workers didn't stop within shutdown timeout")
+	}
+	return nil
 }
 
-func createBucket(session *session.Session) (*string, error) {
-
-	var (
-		bucketName        *string
-		defaultBucketName = "try-imager"
-	)
+// pruneEvents deletes events older than retention every hour until ctx is done, zero retention keeps them.
+func pruneEvents(ctx context.Context, repo model.EventsRepository, retention time.Duration) {
+	if retention == 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		n, err := repo.DeleteBefore(ctx, time.Now().Add(-retention))
+		if err != nil {
+			slog.Error("error deleting expired events", "error", err)
+		} else if n > 0 {
+			slog.Info("deleted expired events", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
 
-	bucketName = &defaultBucketName
+func createBucket(session *session.Session, name string) (*string, error) {
 
-	osEnvBucketName := os.Getenv("BUCKETNAME")
-	if osEnvBucketName != "" {
-		bucketName = &osEnvBucketName
-	}
+	bucketName := &name
 
 	s3session := s3.New(session)
 
@@ -89,3 +244,11 @@ func createBucket(session *session.Session) (*string, error) {
 
 	return bucketName, nil
 }
+
+// checkBucket returns check of the bucket being reachable with credentials of the session.
+func checkBucket(s3session *s3.S3, bucketName *string) health.Check {
+	return func(ctx context.Context) error {
+		_, err := s3session.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: bucketName})
+		return err
+	}
+}
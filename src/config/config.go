@@ -0,0 +1,505 @@
+// Package config loads settings of the imager service from flags, environment and optional YAML or TOML file.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/imager/src/logging"
+	"github.com/imager/src/web/downloader"
+	"gopkg.in/yaml.v2"
+)
+
+// configEnv contains path to the config file when -config flag isn't set.
+const configEnv = "IMAGER_CONFIG"
+
+// redacted replaces secrets when config is printed.
+const redacted = "[REDACTED]"
+
+// StorageS3 stores images in AWS S3 bucket, it's the only storage backend so far.
+const StorageS3 = "s3"
+
+// Config contains settings of the service. Values are taken from defaults, then from the file,
+// then from environment and finally from flags, every next source overrides the previous ones.
+type Config struct {
+	ListenAddr string     `yaml:"listen_addr" toml:"listen_addr"`
+	Server     Server     `yaml:"server" toml:"server"`
+	DB         DB         `yaml:"db" toml:"db"`
+	Storage    Storage    `yaml:"storage" toml:"storage"`
+	Downloader Downloader `yaml:"downloader" toml:"downloader"`
+	Limits     Limits     `yaml:"limits" toml:"limits"`
+	Pool       Pool       `yaml:"pool" toml:"pool"`
+	Jobs       Jobs       `yaml:"jobs" toml:"jobs"`
+	Events     Events     `yaml:"events" toml:"events"`
+	RateLimit  RateLimit  `yaml:"rate_limit" toml:"rate_limit"`
+	Log        Log        `yaml:"log" toml:"log"`
+}
+
+// Server describes timeouts of HTTP server, zero disables timeout.
+type Server struct {
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout"`
+	// ReadTimeout limits reading of the whole request including uploaded body.
+	ReadTimeout Duration `yaml:"read_timeout" toml:"read_timeout"`
+	// WriteTimeout limits time from the end of reading request headers to the end of the response,
+	// event streams are ended before it and resumed by clients.
+	WriteTimeout Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout  Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	// ShutdownTimeout limits time in-flight requests and background workers are waited for on shutdown.
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+// Downloader describes fetching of stored images for resizes and exports.
+type Downloader struct {
+	// AllowedHosts restricts hosts images are fetched from, "*.example.com" allows subdomains.
+	// All hosts are allowed when it's empty.
+	AllowedHosts StringList `yaml:"allowed_hosts" toml:"allowed_hosts"`
+	// BlockedNetworks can't be connected to, private and loopback ranges are blocked by default.
+	// Storage reachable only on a private network, e.g. MinIO, needs its range removed from the list.
+	BlockedNetworks StringList `yaml:"blocked_networks" toml:"blocked_networks"`
+	// MaxBytes limits size of fetched image, zero makes it follow limits.max_body_bytes.
+	MaxBytes     int64 `yaml:"max_bytes" toml:"max_bytes"`
+	MaxRedirects int   `yaml:"max_redirects" toml:"max_redirects"`
+	// MaxRetries is a number of retries after transient failures, every retry waits twice longer than the previous one.
+	MaxRetries            int        `yaml:"max_retries" toml:"max_retries"`
+	RetryDelay            Duration   `yaml:"retry_delay" toml:"retry_delay"`
+	DialTimeout           Duration   `yaml:"dial_timeout" toml:"dial_timeout"`
+	TLSHandshakeTimeout   Duration   `yaml:"tls_handshake_timeout" toml:"tls_handshake_timeout"`
+	ResponseHeaderTimeout Duration   `yaml:"response_header_timeout" toml:"response_header_timeout"`
+	AllowedContentTypes   StringList `yaml:"allowed_content_types" toml:"allowed_content_types"`
+}
+
+// Log describes logs written to stderr.
+type Log struct {
+	// Level is one of debug, info, warn or error.
+	Level  string `yaml:"level" toml:"level"`
+	Format string `yaml:"format" toml:"format"`
+}
+
+// DB describes connection to Postgres, DSN overrides separate connection settings when it's set.
+type DB struct {
+	DSN             string   `yaml:"dsn" toml:"dsn"`
+	Host            string   `yaml:"host" toml:"host"`
+	Port            int      `yaml:"port" toml:"port"`
+	User            string   `yaml:"user" toml:"user"`
+	Password        string   `yaml:"password" toml:"password"`
+	Name            string   `yaml:"name" toml:"name"`
+	SSLMode         string   `yaml:"sslmode" toml:"sslmode"`
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+}
+
+// Storage describes where images are stored.
+type Storage struct {
+	Backend string `yaml:"backend" toml:"backend"`
+	Bucket  string `yaml:"bucket" toml:"bucket"`
+}
+
+// Limits describes sizes of images the service agrees to process.
+type Limits struct {
+	// MaxBodyBytes limits size of uploaded request body and of every file of batch.
+	MaxBodyBytes int64 `yaml:"max_body_bytes" toml:"max_body_bytes"`
+	// MaxBatchBytes limits size of request body of batch import.
+	MaxBatchBytes int64 `yaml:"max_batch_bytes" toml:"max_batch_bytes"`
+	// MaxInputPixels limits width * height of decoded images, it protects against decompression bombs.
+	MaxInputPixels int `yaml:"max_input_pixels" toml:"max_input_pixels"`
+	// MaxOutputWidth and MaxOutputHeight limit size of resized images.
+	MaxOutputWidth  int `yaml:"max_output_width" toml:"max_output_width"`
+	MaxOutputHeight int `yaml:"max_output_height" toml:"max_output_height"`
+}
+
+// DefaultLimits are used when limits aren't set explicitly.
+var DefaultLimits = Limits{
+	MaxBodyBytes:    32 << 20,
+	MaxBatchBytes:   256 << 20,
+	MaxInputPixels:  50000000,
+	MaxOutputWidth:  8192,
+	MaxOutputHeight: 8192,
+}
+
+// Pool describes pool processing images.
+type Pool struct {
+	Workers    int `yaml:"workers" toml:"workers"`
+	QueueDepth int `yaml:"queue_depth" toml:"queue_depth"`
+}
+
+// Jobs describes background workers of asynchronous resizes.
+type Jobs struct {
+	Workers int `yaml:"workers" toml:"workers"`
+}
+
+// Events describes log of image events.
+type Events struct {
+	// Retention is a time events are kept for, zero keeps them forever.
+	Retention Duration `yaml:"retention" toml:"retention"`
+}
+
+// RateLimit describes number of requests per second and burst every client is allowed to make.
+// IPRate and IPBurst limit every IP address before authentication.
+type RateLimit struct {
+	Rate    float64 `yaml:"rate" toml:"rate"`
+	Burst   int     `yaml:"burst" toml:"burst"`
+	IPRate  float64 `yaml:"ip_rate" toml:"ip_rate"`
+	IPBurst int     `yaml:"ip_burst" toml:"ip_burst"`
+}
+
+// Default returns config used when nothing is set explicitly.
+func Default() Config {
+	return Config{
+		ListenAddr: ":8080",
+		Server: Server{
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			ReadTimeout:       Duration(5 * time.Minute),
+			WriteTimeout:      Duration(10 * time.Minute),
+			IdleTimeout:       Duration(2 * time.Minute),
+			ShutdownTimeout:   Duration(30 * time.Second),
+		},
+		DB: DB{
+			Host:            "localhost",
+			Port:            5432,
+			SSLMode:         "disable",
+			MaxOpenConns:    20,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+		},
+		Storage: Storage{Backend: StorageS3, Bucket: "try-imager"},
+		Downloader: Downloader{
+			BlockedNetworks:       append(StringList(nil), downloader.DefaultBlockedCIDRs...),
+			MaxRedirects:          downloader.DefaultMaxRedirects,
+			MaxRetries:            downloader.DefaultMaxRetries,
+			RetryDelay:            Duration(downloader.DefaultRetryDelay),
+			DialTimeout:           Duration(downloader.DefaultTimeouts.Dial),
+			TLSHandshakeTimeout:   Duration(downloader.DefaultTimeouts.TLSHandshake),
+			ResponseHeaderTimeout: Duration(downloader.DefaultTimeouts.ResponseHeader),
+			AllowedContentTypes:   append(StringList(nil), downloader.DefaultAllowedContentTypes...),
+		},
+		Limits:    DefaultLimits,
+		Pool:      Pool{Workers: runtime.NumCPU(), QueueDepth: 4 * runtime.NumCPU()},
+		Jobs:      Jobs{Workers: 2},
+		Events:    Events{Retention: Duration(7 * 24 * time.Hour)},
+		RateLimit: RateLimit{Rate: 10, Burst: 20, IPRate: 50, IPBurst: 100},
+		Log:       Log{Level: "info", Format: logging.FormatJSON},
+	}
+}
+
+// Load returns config built from args without the program name, environment and the config file
+// set by -config flag or IMAGER_CONFIG variable.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	return LoadFlagSet(flag.NewFlagSet("imager", flag.ContinueOnError), args, getenv)
+}
+
+// LoadFlagSet works like Load, fs may contain flags of the command which are parsed along with config flags.
+func LoadFlagSet(fs *flag.FlagSet, args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+	path := fs.String("config", "", "path to YAML or TOML config file, env "+configEnv)
+	envs := cfg.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	// flags override everything else, so they're applied again after the file and environment.
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	cfg = Default()
+	if *path == "" {
+		*path = getenv(configEnv)
+	}
+	if *path != "" {
+		if err := loadFile(*path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+	for name, env := range envs {
+		if v := getenv(env); v != "" {
+			if err := fs.Set(name, v); err != nil {
+				return Config{}, fmt.Errorf("invalid value of %s '%s': %v", env, v, err)
+			}
+		}
+	}
+	for name, v := range explicit {
+		if err := fs.Set(name, v); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// register defines flags setting fields of c and returns environment variables of the flags.
+func (c *Config) register(fs *flag.FlagSet) map[string]string {
+	envs := make(map[string]string)
+	stringVar := func(p *string, name, env, usage string) {
+		fs.StringVar(p, name, *p, usage+", env "+env)
+		envs[name] = env
+	}
+	intVar := func(p *int, name, env, usage string) {
+		fs.IntVar(p, name, *p, usage+", env "+env)
+		envs[name] = env
+	}
+	int64Var := func(p *int64, name, env, usage string) {
+		fs.Int64Var(p, name, *p, usage+", env "+env)
+		envs[name] = env
+	}
+	durationVar := func(p *Duration, name, env, usage string) {
+		fs.Var(p, name, usage+", env "+env)
+		envs[name] = env
+	}
+	listVar := func(p *StringList, name, env, usage string) {
+		fs.Var(p, name, usage+", comma separated, env "+env)
+		envs[name] = env
+	}
+
+	stringVar(&c.ListenAddr, "listen-addr", "IMAGER_LISTEN_ADDR", "address HTTP server listens on")
+	durationVar(&c.Server.ReadHeaderTimeout, "read-header-timeout", "IMAGER_READ_HEADER_TIMEOUT", "time allowed to read request headers")
+	durationVar(&c.Server.ReadTimeout, "read-timeout", "IMAGER_READ_TIMEOUT", "time allowed to read the whole request")
+	durationVar(&c.Server.WriteTimeout, "write-timeout", "IMAGER_WRITE_TIMEOUT", "time allowed to write the response")
+	durationVar(&c.Server.IdleTimeout, "idle-timeout", "IMAGER_IDLE_TIMEOUT", "time idle keep-alive connections are kept")
+	durationVar(&c.Server.ShutdownTimeout, "shutdown-timeout", "IMAGER_SHUTDOWN_TIMEOUT", "time in-flight requests and workers are waited for on shutdown")
+
+	stringVar(&c.DB.DSN, "db-dsn", "IMAGER_DB_DSN", "Postgres connection string, overrides other db settings")
+	stringVar(&c.DB.Host, "db-host", "PGHOST", "Postgres host")
+	intVar(&c.DB.Port, "db-port", "PGPORT", "Postgres port")
+	stringVar(&c.DB.User, "db-user", "PGUSER", "Postgres user")
+	stringVar(&c.DB.Password, "db-password", "PGPASSWORD", "Postgres password")
+	stringVar(&c.DB.Name, "db-name", "PGDBNAME", "Postgres database")
+	stringVar(&c.DB.SSLMode, "db-sslmode", "PGSSLMODE", "Postgres SSL mode")
+	intVar(&c.DB.MaxOpenConns, "db-max-open-conns", "IMAGER_DB_MAX_OPEN_CONNS", "maximal number of open db connections")
+	intVar(&c.DB.MaxIdleConns, "db-max-idle-conns", "IMAGER_DB_MAX_IDLE_CONNS", "maximal number of idle db connections")
+	durationVar(&c.DB.ConnMaxLifetime, "db-conn-max-lifetime", "IMAGER_DB_CONN_MAX_LIFETIME", "maximal time db connection is reused")
+
+	stringVar(&c.Storage.Backend, "storage-backend", "IMAGER_STORAGE_BACKEND", "storage backend of images, one of: "+StorageS3)
+	stringVar(&c.Storage.Bucket, "bucket", "BUCKETNAME", "bucket images are stored in")
+
+	listVar(&c.Downloader.AllowedHosts, "download-allowed-hosts", "IMAGER_DOWNLOAD_ALLOWED_HOSTS", "hosts stored images are fetched from, all when empty")
+	listVar(&c.Downloader.BlockedNetworks, "download-blocked-networks", "IMAGER_DOWNLOAD_BLOCKED_NETWORKS", "networks stored images can't be fetched from, pass empty value to allow all")
+	int64Var(&c.Downloader.MaxBytes, "download-max-bytes", "IMAGER_DOWNLOAD_MAX_BYTES", "maximal size of fetched image, 0 follows max-body-bytes")
+	intVar(&c.Downloader.MaxRedirects, "download-max-redirects", "IMAGER_DOWNLOAD_MAX_REDIRECTS", "maximal number of followed redirects")
+	intVar(&c.Downloader.MaxRetries, "download-max-retries", "IMAGER_DOWNLOAD_MAX_RETRIES", "number of retries after transient failures")
+	durationVar(&c.Downloader.RetryDelay, "download-retry-delay", "IMAGER_DOWNLOAD_RETRY_DELAY", "delay before the first retry")
+	durationVar(&c.Downloader.DialTimeout, "download-dial-timeout", "IMAGER_DOWNLOAD_DIAL_TIMEOUT", "time allowed to connect")
+	durationVar(&c.Downloader.TLSHandshakeTimeout, "download-tls-handshake-timeout", "IMAGER_DOWNLOAD_TLS_HANDSHAKE_TIMEOUT", "time allowed for TLS handshake")
+	durationVar(&c.Downloader.ResponseHeaderTimeout, "download-response-header-timeout", "IMAGER_DOWNLOAD_RESPONSE_HEADER_TIMEOUT", "time allowed to wait for response headers")
+	listVar(&c.Downloader.AllowedContentTypes, "download-allowed-content-types", "IMAGER_DOWNLOAD_ALLOWED_CONTENT_TYPES", "media types of fetched images, type/* allows subtypes")
+
+	int64Var(&c.Limits.MaxBodyBytes, "max-body-bytes", "IMAGER_MAX_BODY_BYTES", "maximal size of uploaded image")
+	int64Var(&c.Limits.MaxBatchBytes, "max-batch-bytes", "IMAGER_MAX_BATCH_BYTES", "maximal size of uploaded batch")
+	intVar(&c.Limits.MaxInputPixels, "max-input-pixels", "IMAGER_MAX_INPUT_PIXELS", "maximal width * height of processed image")
+	intVar(&c.Limits.MaxOutputWidth, "max-output-width", "IMAGER_MAX_OUTPUT_WIDTH", "maximal width of resized image")
+	intVar(&c.Limits.MaxOutputHeight, "max-output-height", "IMAGER_MAX_OUTPUT_HEIGHT", "maximal height of resized image")
+
+	intVar(&c.Pool.Workers, "pool-workers", "POOL_WORKERS", "number of images processed at once")
+	intVar(&c.Pool.QueueDepth, "pool-queue-depth", "POOL_QUEUE_DEPTH", "number of images waiting for processing")
+	intVar(&c.Jobs.Workers, "job-workers", "JOB_WORKERS", "number of background workers of async resizes")
+	durationVar(&c.Events.Retention, "events-retention", "IMAGER_EVENTS_RETENTION", "time events are kept for, 0 keeps them forever")
+
+	fs.Float64Var(&c.RateLimit.Rate, "rate-limit", c.RateLimit.Rate, "requests per second allowed for every client, env IMAGER_RATE_LIMIT")
+	envs["rate-limit"] = "IMAGER_RATE_LIMIT"
+	intVar(&c.RateLimit.Burst, "rate-limit-burst", "IMAGER_RATE_LIMIT_BURST", "burst of requests allowed for every client")
+	fs.Float64Var(&c.RateLimit.IPRate, "rate-limit-ip", c.RateLimit.IPRate, "requests per second allowed for every IP address before authentication, env IMAGER_RATE_LIMIT_IP")
+	envs["rate-limit-ip"] = "IMAGER_RATE_LIMIT_IP"
+	intVar(&c.RateLimit.IPBurst, "rate-limit-ip-burst", "IMAGER_RATE_LIMIT_IP_BURST", "burst of requests allowed for every IP address")
+
+	stringVar(&c.Log.Level, "log-level", "IMAGER_LOG_LEVEL", "minimal level of logged records, one of: debug, info, warn, error")
+	stringVar(&c.Log.Format, "log-format", "IMAGER_LOG_FORMAT", "format of logs, one of: "+logging.FormatJSON+", "+logging.FormatText)
+	return envs
+}
+
+// loadFile reads YAML or TOML file depending on its extension into cfg.
+func loadFile(path string, cfg *Config) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(b, cfg)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(b), cfg)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys: %v", md.Undecoded())
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension '%s', expected .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Validate checks that settings are consistent.
+func (c Config) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+	check(c.ListenAddr != "", "listen address should be set")
+	check(c.Server.ReadHeaderTimeout >= 0 && c.Server.ReadTimeout >= 0 && c.Server.WriteTimeout >= 0 && c.Server.IdleTimeout >= 0,
+		"server timeouts can't be negative")
+	check(c.Server.ShutdownTimeout > 0, "shutdown timeout should be positive")
+	check(c.DB.DSN != "" || c.DB.Host != "", "db host or dsn should be set")
+	check(c.DB.DSN != "" || (c.DB.Port > 0 && c.DB.Port < 65536), "db port should be within 1-65535")
+	check(c.DB.MaxOpenConns >= 0 && c.DB.MaxIdleConns >= 0, "db connection pool sizes can't be negative")
+	check(c.DB.ConnMaxLifetime >= 0, "db connection lifetime can't be negative")
+	check(c.Storage.Backend == StorageS3, "unknown storage backend '%s', expected one of: %s", c.Storage.Backend, StorageS3)
+	check(c.Storage.Bucket != "", "bucket should be set")
+	if _, err := downloader.ParseCIDRs(c.Downloader.BlockedNetworks...); err != nil {
+		errs = append(errs, fmt.Sprintf("invalid blocked network: %v", err))
+	}
+	check(c.Downloader.MaxBytes >= 0 && c.Downloader.MaxRedirects >= 0 && c.Downloader.MaxRetries >= 0,
+		"downloader limits can't be negative")
+	check(c.Downloader.RetryDelay >= 0 && c.Downloader.DialTimeout >= 0 && c.Downloader.TLSHandshakeTimeout >= 0 && c.Downloader.ResponseHeaderTimeout >= 0,
+		"downloader timeouts can't be negative")
+	check(len(c.Downloader.AllowedContentTypes) > 0, "downloader allowed content types should be set")
+	check(c.Limits.MaxBodyBytes > 0 && c.Limits.MaxBatchBytes > 0, "body size limits should be positive")
+	check(c.Limits.MaxInputPixels > 0, "max input pixels should be positive")
+	check(c.Limits.MaxOutputWidth > 0 && c.Limits.MaxOutputHeight > 0, "max output dimensions should be positive")
+	check(c.Pool.Workers > 0, "pool workers should be positive")
+	check(c.Pool.QueueDepth >= 0, "pool queue depth can't be negative")
+	check(c.Jobs.Workers >= 0, "job workers can't be negative")
+	check(c.Events.Retention >= 0, "events retention can't be negative")
+	check(c.RateLimit.Rate > 0 && c.RateLimit.Burst > 0, "rate limit and its burst should be positive")
+	check(c.RateLimit.IPRate > 0 && c.RateLimit.IPBurst > 0, "IP rate limit and its burst should be positive")
+	if _, err := logging.New(ioutil.Discard, c.Log.Format, c.Log.Level); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return errors.New("invalid config: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ConnString returns Postgres connection string, values are quoted, so they may contain spaces and quotes.
+func (db DB) ConnString() string {
+	if db.DSN != "" {
+		return db.DSN
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quoteConnValue(db.Host), db.Port, quoteConnValue(db.User), quoteConnValue(db.Password),
+		quoteConnValue(db.Name), quoteConnValue(db.SSLMode))
+}
+
+// connValueEscaper escapes value of connection string for single quotes.
+var connValueEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func quoteConnValue(v string) string {
+	return "'" + connValueEscaper.Replace(v) + "'"
+}
+
+// Options returns options of downloader, it expects validated config.
+// Size of fetched images follows maxBodyBytes unless MaxBytes is set.
+func (d Downloader) Options(maxBodyBytes int64) []downloader.Option {
+	networks, _ := downloader.ParseCIDRs(d.BlockedNetworks...)
+	maxBytes := d.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = maxBodyBytes
+	}
+	opts := []downloader.Option{
+		downloader.WithBlockedNetworks(networks...),
+		downloader.WithMaxBytes(maxBytes),
+		downloader.WithMaxRedirects(d.MaxRedirects),
+		downloader.WithRetries(d.MaxRetries, time.Duration(d.RetryDelay)),
+		downloader.WithTimeouts(downloader.Timeouts{
+			Dial:           time.Duration(d.DialTimeout),
+			TLSHandshake:   time.Duration(d.TLSHandshakeTimeout),
+			ResponseHeader: time.Duration(d.ResponseHeaderTimeout),
+		}),
+		downloader.WithAllowedContentTypes(d.AllowedContentTypes...),
+	}
+	if len(d.AllowedHosts) > 0 {
+		opts = append(opts, downloader.WithAllowedHosts(d.AllowedHosts...))
+	}
+	return opts
+}
+
+// Redacted returns copy of config without secrets, it's safe to print.
+func (c Config) Redacted() Config {
+	if c.DB.Password != "" {
+		c.DB.Password = redacted
+	}
+	if c.DB.DSN != "" {
+		c.DB.DSN = redacted
+	}
+	return c
+}
+
+// String returns redacted config in YAML.
+func (c Config) String() string {
+	b, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("error marshaling config: %v", err)
+	}
+	return string(b)
+}
+
+// Duration is time.Duration written as string, e.g. 30s, in flags and files.
+type Duration time.Duration
+
+// String implements flag.Value.
+func (d *Duration) String() string {
+	return time.Duration(*d).String()
+}
+
+// Set implements flag.Value.
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// UnmarshalText is used by TOML decoder.
+func (d *Duration) UnmarshalText(b []byte) error {
+	return d.Set(string(b))
+}
+
+// UnmarshalYAML is used by YAML decoder.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return d.Set(s)
+}
+
+// MarshalText is used by encoders.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// StringList is a list set from comma separated value of a flag or environment variable.
+type StringList []string
+
+// String implements flag.Value.
+func (l *StringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value, empty value clears the list.
+func (l *StringList) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
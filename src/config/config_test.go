@@ -0,0 +1,199 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	yamlFile := write("imager.yaml", `
+listen_addr: ":9090"
+db:
+  host: db.internal
+  password: secret
+  conn_max_lifetime: 1m
+storage:
+  bucket: from-file
+pool:
+  workers: 3
+downloader:
+  allowed_hosts: [minio.internal]
+  blocked_networks: []
+`)
+	tomlFile := write("imager.toml", `
+listen_addr = ":9191"
+[db]
+host = "toml.internal"
+conn_max_lifetime = "2m"
+`)
+	unknownKey := write("unknown.yaml", "listen_adr: \":9090\"\n")
+	unknownExt := write("imager.json", "{}")
+
+	type tc struct {
+		name        string
+		args        []string
+		env         map[string]string
+		expectedErr string
+		check       func(t *testing.T, cfg Config)
+	}
+
+	tcs := []tc{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg Config) {
+				if cfg.ListenAddr != ":8080" || cfg.Storage.Bucket != "try-imager" || cfg.DB.SSLMode != "disable" {
+					t.Fatalf("unexpected defaults: %+v", cfg)
+				}
+				if len(cfg.Downloader.BlockedNetworks) == 0 {
+					t.Fatal("private networks should be blocked by default")
+				}
+			},
+		},
+		{
+			name: "yaml file",
+			args: []string{"-config", yamlFile},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.ListenAddr != ":9090" || cfg.DB.Host != "db.internal" || cfg.Pool.Workers != 3 || cfg.Storage.Bucket != "from-file" {
+					t.Fatalf("file isn't applied: %+v", cfg)
+				}
+				if time.Duration(cfg.DB.ConnMaxLifetime) != time.Minute {
+					t.Fatalf("expected conn max lifetime is: 1m but got: %v", cfg.DB.ConnMaxLifetime.String())
+				}
+				if len(cfg.Downloader.AllowedHosts) != 1 || len(cfg.Downloader.BlockedNetworks) != 0 {
+					t.Fatalf("downloader networks should be replaced by file, got: %+v", cfg.Downloader)
+				}
+				if cfg.DB.Port != 5432 {
+					t.Fatalf("defaults of values missing in file should be kept, got port: %d", cfg.DB.Port)
+				}
+			},
+		},
+		{
+			name: "toml file from env",
+			env:  map[string]string{configEnv: tomlFile},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.ListenAddr != ":9191" || cfg.DB.Host != "toml.internal" || time.Duration(cfg.DB.ConnMaxLifetime) != 2*time.Minute {
+					t.Fatalf("file isn't applied: %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "env overrides file and flags override env",
+			args: []string{"-config", yamlFile, "-listen-addr", ":7070"},
+			env:  map[string]string{"IMAGER_LISTEN_ADDR": ":6060", "BUCKETNAME": "from-env", "POOL_WORKERS": "5"},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.ListenAddr != ":7070" || cfg.Storage.Bucket != "from-env" || cfg.Pool.Workers != 5 {
+					t.Fatalf("unexpected precedence: %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "downloader from env and flags",
+			args: []string{"-download-retry-delay", "1s", "-download-allowed-content-types", "image/png, image/jpeg"},
+			env:  map[string]string{"IMAGER_DOWNLOAD_MAX_RETRIES": "4", "IMAGER_DOWNLOAD_RESPONSE_HEADER_TIMEOUT": "30s"},
+			check: func(t *testing.T, cfg Config) {
+				d := cfg.Downloader
+				if d.MaxRetries != 4 || time.Duration(d.RetryDelay) != time.Second || time.Duration(d.ResponseHeaderTimeout) != 30*time.Second {
+					t.Fatalf("downloader settings aren't applied: %+v", d)
+				}
+				if len(d.AllowedContentTypes) != 2 || d.AllowedContentTypes[1] != "image/jpeg" {
+					t.Fatalf("expected two allowed content types, got: %v", d.AllowedContentTypes)
+				}
+			},
+		},
+		{
+			name:        "unknown key",
+			args:        []string{"-config", unknownKey},
+			expectedErr: "listen_adr",
+		},
+		{
+			name:        "unknown extension",
+			args:        []string{"-config", unknownExt},
+			expectedErr: "unsupported config file extension",
+		},
+		{
+			name:        "invalid env",
+			env:         map[string]string{"POOL_WORKERS": "many"},
+			expectedErr: "POOL_WORKERS",
+		},
+		{
+			name:        "invalid config",
+			args:        []string{"-storage-backend", "gcs", "-pool-workers", "0"},
+			expectedErr: "unknown storage backend 'gcs'",
+		},
+		{
+			name:        "invalid blocked network",
+			args:        []string{"-download-blocked-networks", "10.0.0.0/8,private"},
+			expectedErr: "invalid blocked network",
+		},
+		{
+			name:        "invalid log level",
+			env:         map[string]string{"IMAGER_LOG_LEVEL": "verbose"},
+			expectedErr: "invalid log level 'verbose'",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := Load(tc.args, func(name string) string { return tc.env[name] })
+			if tc.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("expected error containing: %s but got: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.check(t, cfg)
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	cfg := Default()
+	cfg.DB.Password = "secret"
+	cfg.DB.DSN = "postgres://imager:secret@db/imager"
+
+	s := cfg.String()
+	if strings.Contains(s, "secret") {
+		t.Fatalf("printed config contains secret:\n%s", s)
+	}
+	if !strings.Contains(s, redacted) || !strings.Contains(s, "conn_max_lifetime: 30m0s") {
+		t.Fatalf("unexpected printed config:\n%s", s)
+	}
+	if cfg.DB.Password != "secret" {
+		t.Fatal("printing config shouldn't change it")
+	}
+}
+
+func TestConnString(t *testing.T) {
+	db := Default().DB
+	db.Host, db.User, db.Password, db.Name = "db", "imager", `it's a \secret pass`, "images"
+
+	expected := `host='db' port=5432 user='imager' password='it\'s a \\secret pass' dbname='images' sslmode='disable'`
+	if s := db.ConnString(); s != expected {
+		t.Fatalf("expected connection string is: %s but got: %s", expected, s)
+	}
+
+	db.DSN = "postgres://imager@db/images"
+	if s := db.ConnString(); s != db.DSN {
+		t.Fatalf("expected dsn to be used, got: %s", s)
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/logging"
+)
+
+const (
+	statusOK   = "ok"
+	statusDown = "down"
+
+	// DefaultTimeout is a time every dependency has to respond to its check.
+	DefaultTimeout = 2 * time.Second
+)
+
+// Check returns error when dependency isn't reachable.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Service checks whether the process can serve requests.
+type Service struct {
+	checks  []namedCheck
+	timeout time.Duration
+}
+
+// Option configures health service.
+type Option func(*Service)
+
+// WithCheck adds dependency which has to be reachable for the process to be ready.
+func WithCheck(name string, check Check) Option {
+	return func(s *Service) {
+		s.checks = append(s.checks, namedCheck{name, check})
+	}
+}
+
+// WithTimeout sets a time every dependency has to respond to its check.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Service) {
+		s.timeout = d
+	}
+}
+
+// NewService creates new Service.
+func NewService(opts ...Option) *Service {
+	s := &Service{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Response describes status of the process and its dependencies.
+type Response struct {
+	Status string
+	Checks map[string]CheckResult `json:",omitempty"`
+}
+
+// CheckResult describes status of a dependency, errors are only logged since probes aren't authenticated.
+type CheckResult struct {
+	Status string
+	// Duration is a time the check took in milliseconds.
+	Duration int64
+}
+
+// Healthz reports that the process is alive, it doesn't check dependencies.
+func (s *Service) Healthz(w http.ResponseWriter, r *http.Request) {
+	write(w, Response{Status: statusOK}, http.StatusOK)
+}
+
+// Readyz checks all dependencies concurrently and responds with 503 if any of them is down.
+func (s *Service) Readyz(w http.ResponseWriter, r *http.Request) {
+	res := Response{Status: statusOK, Checks: make(map[string]CheckResult, len(s.checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range s.checks {
+		wg.Add(1)
+		go func(c namedCheck) {
+			defer wg.Done()
+			result, err := s.run(r.Context(), c.check)
+			mu.Lock()
+			defer mu.Unlock()
+			res.Checks[c.name] = result
+			if err != nil {
+				res.Status = statusDown
+				logging.FromContext(r.Context()).WarnContext(r.Context(), "readiness check failed", "check", c.name, "error", err)
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	statusCode := http.StatusOK
+	if res.Status != statusOK {
+		statusCode = http.StatusServiceUnavailable
+	}
+	write(w, res, statusCode)
+}
+
+func (s *Service) run(ctx context.Context, check Check) (CheckResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{Status: statusOK, Duration: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = statusDown
+	}
+	return result, err
+}
+
+func write(w http.ResponseWriter, res Response, statusCode int) {
+	// probes shouldn't be answered from caches.
+	w.Header().Set("Cache-Control", "no-store")
+	data, err := json.Marshal(res)
+	if err != nil {
+		slog.Error("error marshaling health response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	apierror.Write(w, data, statusCode)
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadyz(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	failing := func(context.Context) error { return errors.New("connection refused") }
+	hanging := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	type tc struct {
+		name               string
+		opts               []Option
+		expectedStatusCode int
+		expectedChecks     map[string]string
+	}
+
+	tcs := []tc{
+		{
+			name:               "http.StatusOK: no dependencies",
+			expectedStatusCode: http.StatusOK,
+			expectedChecks:     map[string]string{},
+		},
+		{
+			name:               "http.StatusOK",
+			opts:               []Option{WithCheck("db", ok), WithCheck("storage", ok)},
+			expectedStatusCode: http.StatusOK,
+			expectedChecks:     map[string]string{"db": statusOK, "storage": statusOK},
+		},
+		{
+			name:               "http.StatusServiceUnavailable: dependency is down",
+			opts:               []Option{WithCheck("db", ok), WithCheck("storage", failing)},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedChecks:     map[string]string{"db": statusOK, "storage": statusDown},
+		},
+		{
+			name:               "http.StatusServiceUnavailable: dependency timed out",
+			opts:               []Option{WithCheck("db", hanging), WithTimeout(time.Millisecond)},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedChecks:     map[string]string{"db": statusDown},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			NewService(tc.opts...).Readyz(wr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+			if strings.Contains(wr.Body.String(), "connection refused") {
+				t.Fatalf("expected errors of dependencies to be hidden but got: %s", wr.Body.String())
+			}
+			var res Response
+			if err := json.NewDecoder(wr.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Checks) != len(tc.expectedChecks) {
+				t.Fatalf("expected checks are: %v but got: %v", tc.expectedChecks, res.Checks)
+			}
+			for name, status := range tc.expectedChecks {
+				if res.Checks[name].Status != status {
+					t.Fatalf("expected status of %s is: %s but got: %+v", name, status, res.Checks[name])
+				}
+			}
+		})
+	}
+}
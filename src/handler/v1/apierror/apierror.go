@@ -0,0 +1,117 @@
+// Package apierror describes errors returned by API handlers.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/imager/src/logging"
+)
+
+// Codes shared by all handlers.
+const (
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeInternal     = "internal_error"
+	CodeBodyTooLarge = "body_too_large"
+
+	// MaxJSONBytes limits size of JSON request bodies, they only describe what should be done.
+	MaxJSONBytes = 64 << 10
+)
+
+// Response is a body of every failed request.
+type Response struct {
+	Error Error
+}
+
+// Error describes why request failed.
+type Error struct {
+	Code      string
+	Message   string
+	RequestID string
+}
+
+// New returns JSON body describing the failure and logs it.
+// Details of server faults are only logged, clients get the message without the underlying error.
+func New(w http.ResponseWriter, r *http.Request, statusCode int, code, message string, err error) ([]byte, int) {
+	e := NewError(w, r, statusCode, code, message, err)
+	b, marshalErr := json.Marshal(Response{Error: e})
+	if marshalErr != nil {
+		logging.FromContext(r.Context()).Error("error marshaling error response", "error", marshalErr)
+		return nil, statusCode
+	}
+	return b, statusCode
+}
+
+// NewError describes the failure and logs it the same way as New, it's used for failures of parts of requests.
+// Server faults are logged as errors, rejected requests as warnings and their clients get ClientMessage.
+func NewError(w http.ResponseWriter, r *http.Request, statusCode int, code, message string, err error) Error {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+	if statusCode >= http.StatusInternalServerError {
+		logger.ErrorContext(ctx, message, "status", statusCode, "code", code, "error", err)
+	} else {
+		logger.WarnContext(ctx, message, "status", statusCode, "code", code, "error", err)
+		message = ClientMessage(message, err)
+	}
+	return Error{Code: code, Message: message, RequestID: logging.RequestID(ctx)}
+}
+
+// ClientMessage returns message of rejected request with err safe to show to its client.
+// Wrapping errors may contain stored data or db details, so only the outermost error created by WithDetails
+// is shown, or the innermost error when there is none.
+func ClientMessage(message string, err error) string {
+	if err == nil {
+		return message
+	}
+	var detailed *detailedError
+	if errors.As(err, &detailed) {
+		return message + ": " + detailed.Error()
+	}
+	return message + ": " + cause(err).Error()
+}
+
+// detailedError is err with details which are safe to show to clients.
+type detailedError struct {
+	err     error
+	details string
+}
+
+// WithDetails wraps err with details shown to clients, e.g. limits the request exceeded.
+func WithDetails(err error, format string, args ...interface{}) error {
+	return &detailedError{err: err, details: fmt.Sprintf(format, args...)}
+}
+
+func (e *detailedError) Error() string {
+	return e.err.Error() + ": " + e.details
+}
+
+func (e *detailedError) Unwrap() error {
+	return e.err
+}
+
+// cause returns the innermost error wrapped by err, it's a sentinel error or a validation error.
+func cause(err error) error {
+	for {
+		wrapped := errors.Unwrap(err)
+		if wrapped == nil {
+			return err
+		}
+		err = wrapped
+	}
+}
+
+// IsBodyTooLarge tells whether reading of body failed because it exceeded limit of http.MaxBytesReader.
+func IsBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// Write writes JSON data with status code.
+func Write(w http.ResponseWriter, data []byte, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(data)
+}
@@ -0,0 +1,99 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/imager/src/logging"
+)
+
+var (
+	errConflict      = errors.New("conflict")
+	errQuotaExceeded = errors.New("quota exceeded")
+)
+
+func TestNew(t *testing.T) {
+	type tc struct {
+		name            string
+		requestID       string
+		statusCode      int
+		code            string
+		err             error
+		expectedMessage string
+	}
+
+	tcs := []tc{
+		{
+			name:            "client error contains details",
+			requestID:       "abc",
+			statusCode:      http.StatusBadRequest,
+			code:            "invalid_params",
+			err:             errors.New("width is lower or equal 0"),
+			expectedMessage: "error validating resize params: width is lower or equal 0",
+		},
+		{
+			name:            "client error contains only wrapped sentinel",
+			statusCode:      http.StatusConflict,
+			code:            "conflict",
+			err:             fmt.Errorf("inserting of image {1 http://images/1.png} failed: %w", fmt.Errorf("%w: duplicate key value", errConflict)),
+			expectedMessage: "error validating resize params: conflict",
+		},
+		{
+			name:            "client error keeps outermost details",
+			statusCode:      http.StatusTooManyRequests,
+			code:            "quota_exceeded",
+			err:             fmt.Errorf("error saving image: %w", WithDetails(errQuotaExceeded, "quota is %d images", 10)),
+			expectedMessage: "error validating resize params: quota exceeded: quota is 10 images",
+		},
+		{
+			name:            "server error hides details",
+			statusCode:      http.StatusInternalServerError,
+			code:            CodeInternal,
+			err:             errors.New("pq: connection refused"),
+			expectedMessage: "error validating resize params",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/images", nil)
+			if tc.requestID != "" {
+				r = r.WithContext(logging.WithRequestID(r.Context(), tc.requestID))
+			}
+
+			data, statusCode := New(wr, r, tc.statusCode, tc.code, "error validating resize params", tc.err)
+			Write(wr, data, statusCode)
+
+			res := wr.Result()
+			if res.StatusCode != tc.statusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.statusCode, res.StatusCode)
+			}
+			if contentType := res.Header.Get("Content-Type"); contentType != "application/json" {
+				t.Fatalf("expected content type is: application/json but got: %s", contentType)
+			}
+
+			var body Response
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error.Code != tc.code {
+				t.Fatalf("expected code is: %s but got: %s", tc.code, body.Error.Code)
+			}
+			if body.Error.Message != tc.expectedMessage {
+				t.Fatalf("expected message is: %s but got: %s", tc.expectedMessage, body.Error.Message)
+			}
+			if body.Error.RequestID != tc.requestID {
+				t.Fatalf("expected request id is: %s but got: %s", tc.requestID, body.Error.RequestID)
+			}
+			if tc.statusCode >= http.StatusInternalServerError && strings.Contains(body.Error.Message, tc.err.Error()) {
+				t.Fatalf("expected server error details to be hidden, got: %s", body.Error.Message)
+			}
+		})
+	}
+}
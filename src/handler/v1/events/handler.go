@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/logging"
+	"github.com/imager/src/model"
+)
+
+const (
+	codeInvalidParams = "invalid_params"
+
+	// lastEventIDHeader is sent by EventSource when it reconnects.
+	lastEventIDHeader = "Last-Event-ID"
+	// batchSize is a number of events read from the log at once.
+	batchSize = 100
+
+	// DefaultPollInterval is a time between checks of the log for new events.
+	DefaultPollInterval = time.Second
+	// DefaultHeartbeat is a time between comments keeping idle connections open through proxies.
+	DefaultHeartbeat = 15 * time.Second
+)
+
+// Service contains events repository.
+type Service struct {
+	repo         model.EventsRepository
+	pollInterval time.Duration
+	heartbeat    time.Duration
+	maxDuration  time.Duration
+	done         <-chan struct{}
+}
+
+// Option configures events service.
+type Option func(*Service)
+
+// WithMaxDuration ends streams after d, so they don't outlive write timeout of the server.
+// Clients reconnect and resume from the last event they got.
+func WithMaxDuration(d time.Duration) Option {
+	return func(s *Service) {
+		s.maxDuration = d
+	}
+}
+
+// WithDone ends all streams when done is closed, e.g. when the server shuts down.
+func WithDone(done <-chan struct{}) Option {
+	return func(s *Service) {
+		s.done = done
+	}
+}
+
+// NewService creates new Service.
+func NewService(repo model.EventsRepository, opts ...Option) *Service {
+	s := &Service{repo: repo, pollInterval: DefaultPollInterval, heartbeat: DefaultHeartbeat}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Stream sends events of tenant of the caller as server-sent events until the client disconnects.
+// Clients resuming with Last-Event-ID header (or lastEventId param) get events they missed,
+// others get only events which happen after they connect.
+func (s *Service) Stream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		data, statusCode := apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, "streaming isn't supported", nil)
+		apierror.Write(w, data, statusCode)
+		return
+	}
+	lastID, resume, err := lastEventID(r)
+	if err != nil {
+		data, statusCode := apierror.New(w, r, http.StatusBadRequest, codeInvalidParams, "error validating last event id", err)
+		apierror.Write(w, data, statusCode)
+		return
+	}
+	if !resume {
+		if lastID, err = s.repo.LastID(ctx); err != nil {
+			data, statusCode := apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, "error getting last event", err)
+			apierror.Write(w, data, statusCode)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	poll := time.NewTicker(s.pollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(s.heartbeat)
+	defer heartbeat.Stop()
+	// nil channel never fires, so streams aren't limited by default.
+	var expired <-chan time.Time
+	if s.maxDuration > 0 {
+		timer := time.NewTimer(s.maxDuration)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		events, err := s.repo.After(ctx, lastID, batchSize)
+		if err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "error getting events", "after_id", lastID, "error", err)
+		}
+		for _, event := range events {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			lastID = event.ID
+		}
+		if len(events) > 0 {
+			flusher.Flush()
+		}
+		if len(events) == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		case <-expired:
+			return
+		case <-poll.C:
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event model.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// lastEventID returns id of the last event seen by the client and whether it was sent at all.
+func lastEventID(r *http.Request) (int, bool, error) {
+	v := r.Header.Get(lastEventIDHeader)
+	if v == "" {
+		v = r.URL.Query().Get("lastEventId")
+	}
+	if v == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil || id < 0 {
+		return 0, false, fmt.Errorf("invalid last event id '%s'", v)
+	}
+	return id, true, nil
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mock_model "github.com/imager/src/mock/model"
+	"github.com/imager/src/model"
+)
+
+func TestStream(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	event := model.Event{ID: 8, Type: model.EventImageCreated, TenantID: model.DefaultTenant, Image: model.Image{ID: 1}}
+
+	type tc struct {
+		name               string
+		lastEventID        string
+		getRepo            func(repo *mock_model.MockEventsRepository, cancel context.CancelFunc)
+		expectedStatusCode int
+		expectedBody       string
+	}
+
+	tcs := []tc{
+		{
+			name:               "http.StatusBadRequest: invalid last event id",
+			lastEventID:        "abc",
+			getRepo:            func(*mock_model.MockEventsRepository, context.CancelFunc) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusInternalServerError: last id error",
+			getRepo: func(repo *mock_model.MockEventsRepository, _ context.CancelFunc) {
+				repo.EXPECT().LastID(gomock.Any()).Return(0, errors.New("error"))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "http.StatusOK: new events only",
+			getRepo: func(repo *mock_model.MockEventsRepository, cancel context.CancelFunc) {
+				repo.EXPECT().LastID(gomock.Any()).Return(7, nil)
+				repo.EXPECT().After(gomock.Any(), 7, batchSize).Return([]model.Event{event}, nil)
+				repo.EXPECT().After(gomock.Any(), 8, batchSize).DoAndReturn(func(context.Context, int, int) ([]model.Event, error) {
+					cancel()
+					return nil, nil
+				})
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "id: 8\nevent: image.created\ndata: {\"ID\":8,",
+		},
+		{
+			name:        "http.StatusOK: resumed after last event id",
+			lastEventID: "3",
+			getRepo: func(repo *mock_model.MockEventsRepository, cancel context.CancelFunc) {
+				repo.EXPECT().After(gomock.Any(), 3, batchSize).DoAndReturn(func(context.Context, int, int) ([]model.Event, error) {
+					cancel()
+					return []model.Event{event}, nil
+				})
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "id: 8\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			repo := mock_model.NewMockEventsRepository(mockCtrl)
+			tc.getRepo(repo, cancel)
+
+			svc := NewService(repo)
+			svc.pollInterval = time.Millisecond
+
+			wr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/events", nil).WithContext(ctx)
+			if tc.lastEventID != "" {
+				r.Header.Set(lastEventIDHeader, tc.lastEventID)
+			}
+			svc.Stream(wr, r)
+
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+			if !strings.HasPrefix(wr.Body.String(), tc.expectedBody) {
+				t.Fatalf("expected body to start with: %q but got: %q", tc.expectedBody, wr.Body.String())
+			}
+		})
+	}
+}
+
+func TestStreamEnds(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	closed := make(chan struct{})
+	close(closed)
+
+	type tc struct {
+		name string
+		opts []Option
+	}
+
+	tcs := []tc{
+		{
+			name: "server shutdown",
+			opts: []Option{WithDone(closed)},
+		},
+		{
+			name: "max duration",
+			opts: []Option{WithMaxDuration(time.Millisecond)},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := mock_model.NewMockEventsRepository(mockCtrl)
+			repo.EXPECT().LastID(gomock.Any()).Return(0, nil)
+			repo.EXPECT().After(gomock.Any(), 0, batchSize).Return(nil, nil).AnyTimes()
+
+			svc := NewService(repo, tc.opts...)
+			svc.pollInterval = time.Millisecond
+
+			wr := httptest.NewRecorder()
+			svc.Stream(wr, httptest.NewRequest(http.MethodGet, "/api/v1/events", nil))
+
+			if statusCode := wr.Result().StatusCode; statusCode != http.StatusOK {
+				t.Fatalf("expected status code is: %d but got: %d", http.StatusOK, statusCode)
+			}
+		})
+	}
+}
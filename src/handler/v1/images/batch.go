@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/model"
+)
+
+const (
+	// maxBatchEntries limits number of files in one batch.
+	maxBatchEntries = 100
+	// batchConcurrency is a number of files of one batch processed at once.
+	batchConcurrency = 4
+)
+
+var (
+	// errTooManyEntries is returned when batch contains more than maxBatchEntries files.
+	errTooManyEntries = fmt.Errorf("batch contains more than %d files", maxBatchEntries)
+	// errEntryTooLarge is returned when file of batch exceeds MaxBodyBytes.
+	errEntryTooLarge = errors.New("file is too large")
+	// errBatchTooLarge is returned when decompressed files of batch exceed MaxBatchBytes together.
+	errBatchTooLarge = errors.New("files of batch are too large")
+)
+
+// BatchEntry describes result of import of one file of batch.
+type BatchEntry struct {
+	Name   string
+	Status int
+	Result *model.OriginalResized `json:",omitempty"`
+	Error  *apierror.Error        `json:",omitempty"`
+}
+
+// BatchResponse describes results of all files of batch in order they were sent.
+type BatchResponse struct {
+	Succeeded int
+	Failed    int
+	Entries   []BatchEntry
+}
+
+// batchFile is a file of batch, read returns its content. Files are read by workers importing them,
+// so only files being imported are held in memory.
+type batchFile struct {
+	name string
+	read func(budget *batchBudget) ([]byte, error)
+}
+
+// Batch creates and resizes every image of zip archive or of multipart form with many file parts.
+// Files are processed independently, failure of one doesn't affect others. When the body can't be read
+// to the end, the batch fails while files imported before are kept.
+func (s *Service) Batch(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func(w http.ResponseWriter, r *http.Request) ([]byte, int) {
+		weight, height, err := validateSizeParams(r)
+		if err != nil {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "error validating resize params", err)
+		}
+		opts, err := validateResizeOptions(r)
+		if err != nil {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "error validating resize params", err)
+		}
+		if err := validateOutputSize(weight, height, s.limits); err != nil {
+			return errorResponse(w, r, http.StatusUnprocessableEntity, codeInvalidParams, "error validating resize params", err)
+		}
+
+		if r.Body == nil {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidBody, "request body is empty", nil)
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.limits.MaxBatchBytes)
+
+		next, err := s.readBatch(r)
+		if err != nil {
+			return s.batchError(w, r, err)
+		}
+		res, err := s.importBatch(w, r, next, weight, height, opts)
+		if err != nil {
+			return s.batchError(w, r, err)
+		}
+		if len(res.Entries) == 0 {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidBody, "batch contains no files", nil)
+		}
+
+		b, err := json.Marshal(res)
+		if err != nil {
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error marshaling result", err)
+		}
+		return b, http.StatusOK
+	}(w, r)
+	response(w, data, statusCode)
+}
+
+// batchError describes failure of reading of the batch body.
+func (s *Service) batchError(w http.ResponseWriter, r *http.Request, err error) ([]byte, int) {
+	if apierror.IsBodyTooLarge(err) {
+		return errorResponse(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge, fmt.Sprintf("request body is too large, at most %d bytes are allowed", s.limits.MaxBatchBytes), nil)
+	}
+	return errorResponse(w, r, http.StatusBadRequest, codeInvalidBody, "error reading batch", err)
+}
+
+// importBatch imports files returned by next until it returns io.EOF. The next file is taken only
+// when a worker is free, so files of multipart streams are read as they're imported.
+func (s *Service) importBatch(w http.ResponseWriter, r *http.Request, next func() (batchFile, error), weight, height int, opts resizeOptions) (BatchResponse, error) {
+	budget := &batchBudget{remaining: s.limits.MaxBatchBytes}
+	var (
+		entries []*BatchEntry
+		readErr error
+		wg      sync.WaitGroup
+	)
+	sem := make(chan struct{}, batchConcurrency)
+	for {
+		sem <- struct{}{}
+		f, err := next()
+		if err != nil {
+			<-sem
+			if err != io.EOF {
+				readErr = err
+			}
+			break
+		}
+		if len(entries) == maxBatchEntries {
+			<-sem
+			readErr = errTooManyEntries
+			break
+		}
+		entry := &BatchEntry{}
+		entries = append(entries, entry)
+		wg.Add(1)
+		go func(f batchFile) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			*entry = s.importFile(w, r, f, budget, weight, height, opts)
+		}(f)
+	}
+	wg.Wait()
+	if readErr != nil {
+		return BatchResponse{}, readErr
+	}
+
+	res := BatchResponse{Entries: make([]BatchEntry, len(entries))}
+	for i, entry := range entries {
+		res.Entries[i] = *entry
+		if entry.Error == nil {
+			res.Succeeded++
+		} else {
+			res.Failed++
+		}
+	}
+	return res, nil
+}
+
+// importFile reads file of batch and runs it through the Resize pipeline.
+func (s *Service) importFile(w http.ResponseWriter, r *http.Request, f batchFile, budget *batchBudget, weight, height int, opts resizeOptions) BatchEntry {
+	b, err := f.read(budget)
+	var res model.OriginalResized
+	if err == nil {
+		res, err = s.resize(r.Context(), b, weight, height, opts)
+	}
+	if err != nil {
+		statusCode, code := errorStatus(err)
+		if errors.Is(err, errEntryTooLarge) || errors.Is(err, errBatchTooLarge) {
+			statusCode, code = http.StatusRequestEntityTooLarge, codeBodyTooLarge
+		}
+		e := apierror.NewError(w, r, statusCode, code, fmt.Sprintf("error importing file %s", f.name), err)
+		return BatchEntry{Name: f.name, Status: statusCode, Error: &e}
+	}
+	return BatchEntry{Name: f.name, Status: http.StatusCreated, Result: &res}
+}
+
+// readBatch returns func iterating over files of zip archive or of file parts of multipart form.
+func (s *Service) readBatch(r *http.Request) (func() (batchFile, error), error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type: %v", err)
+	}
+	switch mediaType {
+	case "application/zip", "application/x-zip-compressed":
+		return s.readZip(r.Body)
+	case "multipart/form-data":
+		return s.readMultipart(r)
+	default:
+		return nil, fmt.Errorf("unsupported content type '%s', expected application/zip or multipart/form-data", mediaType)
+	}
+}
+
+// readZip reads the archive, which is limited by MaxBatchBytes, entries are decompressed only when they're imported.
+func (s *Service) readZip(body io.Reader) (func() (batchFile, error), error) {
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %v", err)
+	}
+
+	var files []*zip.File
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() || skippedZipEntry(zf.Name) {
+			continue
+		}
+		if len(files) == maxBatchEntries {
+			return nil, errTooManyEntries
+		}
+		files = append(files, zf)
+	}
+	return func() (batchFile, error) {
+		if len(files) == 0 {
+			return batchFile{}, io.EOF
+		}
+		zf := files[0]
+		files = files[1:]
+		return batchFile{name: zf.Name, read: func(budget *batchBudget) ([]byte, error) {
+			return s.readZipFile(zf, budget)
+		}}, nil
+	}, nil
+}
+
+// readZipFile reads at most MaxBodyBytes of entry, sizes in headers of the archive aren't trusted.
+func (s *Service) readZipFile(zf *zip.File, budget *batchBudget) ([]byte, error) {
+	if zf.UncompressedSize64 > uint64(s.limits.MaxBodyBytes) {
+		return nil, apierror.WithDetails(errEntryTooLarge, "at most %d bytes are allowed", s.limits.MaxBodyBytes)
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer rc.Close()
+	return s.readEntry(rc, budget)
+}
+
+// readMultipart iterates over file parts of the form, every part is read when it's taken
+// as the stream can't be read out of order.
+func (s *Service) readMultipart(r *http.Request) (func() (batchFile, error), error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	return func() (batchFile, error) {
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				return batchFile{}, err
+			}
+			if part.FormName() != "file" {
+				part.Close()
+				continue
+			}
+			b, err := s.readEntry(part, nil)
+			part.Close()
+			// failed read of the body fails the whole batch, the rest of it can't be read anyway.
+			if apierror.IsBodyTooLarge(err) {
+				return batchFile{}, err
+			}
+			return batchFile{name: part.FileName(), read: func(*batchBudget) ([]byte, error) {
+				return b, err
+			}}, nil
+		}
+	}, nil
+}
+
+// readEntry reads file of batch which is allowed to be at most MaxBodyBytes,
+// read bytes are taken from budget unless it's nil.
+func (s *Service) readEntry(r io.Reader, budget *batchBudget) ([]byte, error) {
+	if budget != nil {
+		r = &budgetReader{r: r, budget: budget}
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, s.limits.MaxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > s.limits.MaxBodyBytes {
+		return nil, apierror.WithDetails(errEntryTooLarge, "at most %d bytes are allowed", s.limits.MaxBodyBytes)
+	}
+	return b, nil
+}
+
+// batchBudget limits total size of decompressed files of batch, as entries of small archive
+// can decompress to much more than the archive itself.
+type batchBudget struct {
+	remaining int64
+}
+
+// budgetReader takes bytes read from r from budget and fails once the budget is spent.
+type budgetReader struct {
+	r      io.Reader
+	budget *batchBudget
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if n > 0 && atomic.AddInt64(&b.budget.remaining, -int64(n)) < 0 {
+		return n, errBatchTooLarge
+	}
+	return n, err
+}
+
+// skippedZipEntry reports whether entry is metadata added by archivers rather than image.
+func skippedZipEntry(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".")
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/imager/src/config"
+	mock_model "github.com/imager/src/mock/model"
+	mock_uploader "github.com/imager/src/mock/uploader"
+)
+
+func TestBatch(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	original, err := readImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := []byte("not an image")
+
+	tooMany := make(map[string][]byte, maxBatchEntries+1)
+	for i := 0; i <= maxBatchEntries; i++ {
+		tooMany[fmt.Sprintf("%d.jpg", i)] = corrupt
+	}
+
+	// importing service stores one original and one resized image.
+	importing := func() *Service {
+		uploadSvc := mock_uploader.NewMockService(mockCtrl)
+		uploadSvc.EXPECT().Upload(gomock.Any(), gomock.Any(), gomock.Any()).Return("http://images/1.png", nil).Times(2)
+		repo := mock_model.NewMockImagesRepository(mockCtrl)
+		repo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(1, nil).Times(2)
+		return NewService(repo, uploadSvc, nil)
+	}
+
+	type tc struct {
+		name               string
+		getBody            func() (io.Reader, string, error)
+		getTest            func() *Service
+		expectedStatusCode int
+		expectedSucceeded  int
+		expectedFailed     int
+		expectedTooLarge   int
+	}
+
+	tcs := []tc{
+		{
+			name: "http.StatusBadRequest: unsupported content type",
+			getBody: func() (io.Reader, string, error) {
+				return bytes.NewReader(original), "image/jpeg", nil
+			},
+			getTest: func() *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusBadRequest: invalid zip",
+			getBody: func() (io.Reader, string, error) {
+				return bytes.NewReader(corrupt), "application/zip", nil
+			},
+			getTest: func() *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusBadRequest: too many files",
+			getBody: func() (io.Reader, string, error) {
+				return zipBody(tooMany)
+			},
+			getTest: func() *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusRequestEntityTooLarge",
+			getBody: func() (io.Reader, string, error) {
+				return zipBody(map[string][]byte{"test.jpg": original})
+			},
+			getTest: func() *Service {
+				limits := config.DefaultLimits
+				limits.MaxBatchBytes = 1024
+				return NewService(nil, nil, nil, WithLimits(limits))
+			},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "http.StatusOK: decompressed files exceed batch limit",
+			getBody: func() (io.Reader, string, error) {
+				return zipBody(map[string][]byte{
+					"a.jpg": bytes.Repeat([]byte{0}, 2048),
+					"b.jpg": bytes.Repeat([]byte{0}, 2048),
+				})
+			},
+			getTest: func() *Service {
+				limits := config.DefaultLimits
+				limits.MaxBatchBytes = 1024
+				return NewService(nil, nil, nil, WithLimits(limits))
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedFailed:     2,
+			expectedTooLarge:   2,
+		},
+		{
+			name: "http.StatusOK: zip with corrupt file and metadata",
+			getBody: func() (io.Reader, string, error) {
+				return zipBody(map[string][]byte{
+					"photos/test.jpg":            original,
+					"photos/broken.jpg":          corrupt,
+					"__MACOSX/photos/._test.jpg": corrupt,
+				})
+			},
+			getTest:            importing,
+			expectedStatusCode: http.StatusOK,
+			expectedSucceeded:  1,
+			expectedFailed:     1,
+		},
+		{
+			name: "http.StatusOK: multipart with many files",
+			getBody: func() (io.Reader, string, error) {
+				body := new(bytes.Buffer)
+				mw := multipart.NewWriter(body)
+				for name, b := range map[string][]byte{"test.jpg": original, "broken.jpg": corrupt} {
+					w, err := mw.CreateFormFile("file", name)
+					if err != nil {
+						return nil, "", err
+					}
+					if _, err := w.Write(b); err != nil {
+						return nil, "", err
+					}
+				}
+				if err := mw.Close(); err != nil {
+					return nil, "", err
+				}
+				return body, mw.FormDataContentType(), nil
+			},
+			getTest:            importing,
+			expectedStatusCode: http.StatusOK,
+			expectedSucceeded:  1,
+			expectedFailed:     1,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			body, contentType, err := tc.getBody()
+			if err != nil {
+				t.Fatal(err)
+			}
+			wr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/images/batch?weight=10&height=10", body)
+			r.Header.Set("Content-Type", contentType)
+			tc.getTest().Batch(wr, r)
+
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+			if statusCode != http.StatusOK {
+				return
+			}
+			var res BatchResponse
+			if err := json.Unmarshal(wr.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Succeeded != tc.expectedSucceeded || res.Failed != tc.expectedFailed {
+				t.Fatalf("expected %d succeeded and %d failed files but got: %+v", tc.expectedSucceeded, tc.expectedFailed, res)
+			}
+			tooLarge := 0
+			for _, entry := range res.Entries {
+				if entry.Status == http.StatusRequestEntityTooLarge {
+					tooLarge++
+				}
+			}
+			if tooLarge != tc.expectedTooLarge {
+				t.Fatalf("expected %d files over the batch limit but got: %+v", tc.expectedTooLarge, res.Entries)
+			}
+		})
+	}
+}
+
+func zipBody(files map[string][]byte) (io.Reader, string, error) {
+	body := new(bytes.Buffer)
+	zw := zip.NewWriter(body)
+	for name, b := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := w.Write(b); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+	return body, "application/zip", nil
+}
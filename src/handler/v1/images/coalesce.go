@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// coalescer shares result of one call among concurrent calls with the same key.
+type coalescer struct {
+	group singleflight.Group
+}
+
+// do calls fn once for all concurrent calls with key. fn gets context detached from cancellation of ctx,
+// so the shared computation isn't aborted when the caller which started it goes away,
+// while the caller itself stops waiting when its ctx is done.
+func (c *coalescer) do(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		return fn(detachedContext{ctx})
+	})
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// detachedContext keeps values of the parent, e.g. tenant, but is never canceled.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
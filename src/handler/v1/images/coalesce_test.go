@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_downloader "github.com/imager/src/mock/downloader"
+	mock_model "github.com/imager/src/mock/model"
+	mock_uploader "github.com/imager/src/mock/uploader"
+	"github.com/imager/src/model"
+)
+
+func TestResizeByIDCoalescesConcurrentRequests(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	const requests = 50
+	weight, height := 100, 100
+
+	original, err := readImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+	downloadSvc := mock_downloader.NewMockService(mockCtrl)
+	uploadSvc := mock_uploader.NewMockService(mockCtrl)
+	svc := NewService(imagesSvc, uploadSvc, downloadSvc)
+
+	// the download is held until every request waits for the resize, so all of them share it.
+	release := make(chan struct{})
+	imagesSvc.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{ID: 1, DownloadURL: "http://storage/original.jpg"}, nil).Times(1)
+	downloadSvc.EXPECT().Download(gomock.Any(), "http://storage/original.jpg").
+		DoAndReturn(func(ctx context.Context, url string) ([]byte, error) {
+			<-release
+			return original, nil
+		}).Times(1)
+	uploadSvc.EXPECT().Upload(gomock.Any(), gomock.Any(), gomock.Any()).Return("http://storage/resized.png", nil).Times(1)
+	imagesSvc.EXPECT().Save(gomock.Any(), gomock.Any()).Return(2, nil).Times(1)
+
+	waiting := make(chan struct{}, requests)
+	var wg sync.WaitGroup
+	bodies := make([][]byte, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r, wr, err := createRecorderAndRequest("1", weight, height)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			svc.ResizeByID(wr, r.WithContext(&waitingContext{Context: r.Context(), waiting: waiting}))
+			if statusCode := wr.Result().StatusCode; statusCode != http.StatusCreated {
+				t.Errorf("expected status code is: %d but got: %d", http.StatusCreated, statusCode)
+			}
+			bodies[i] = wr.Body.Bytes()
+		}(i)
+	}
+	for i := 0; i < requests; i++ {
+		<-waiting
+	}
+	close(release)
+	wg.Wait()
+
+	for i := 1; i < requests; i++ {
+		if !bytes.Equal(bodies[i], bodies[0]) {
+			t.Fatalf("expected all requests to get the same result, got: %s and %s", bodies[0], bodies[i])
+		}
+	}
+}
+
+// waitingContext reports when its Done channel is requested for the first time,
+// requests do it once they wait for the result of the coalesced call.
+type waitingContext struct {
+	context.Context
+	once    sync.Once
+	waiting chan<- struct{}
+}
+
+func (c *waitingContext) Done() <-chan struct{} {
+	c.once.Do(func() { c.waiting <- struct{}{} })
+	return c.Context.Done()
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/model"
+	"github.com/imager/src/pool"
+	"github.com/imager/src/web/downloader"
+)
+
+// Error codes let clients handle failures without parsing messages.
+const (
+	codeInvalidParams = "invalid_params"
+	codeInvalidID     = "invalid_id"
+	codeInvalidBody   = "invalid_body"
+	codeBodyTooLarge  = apierror.CodeBodyTooLarge
+	codeImageTooLarge = "image_too_large"
+	codeUnprocessable = "unprocessable_image"
+	codeUnsupported   = "unsupported_format"
+	codeCorruptImage  = "corrupt_image"
+	codeNotFound      = "not_found"
+	codeConflict      = "conflict"
+	codeQuotaExceeded = "quota_exceeded"
+	codeOverloaded    = "overloaded"
+	codeCanceled      = "request_canceled"
+	codeInternal      = apierror.CodeInternal
+)
+
+func errorResponse(w http.ResponseWriter, r *http.Request, statusCode int, code, message string, err error) ([]byte, int) {
+	if code == codeQuotaExceeded {
+		w.Header().Set("Retry-After", strconv.Itoa(int(quotaRetryAfter.Seconds())))
+	}
+	return apierror.New(w, r, statusCode, code, message, err)
+}
+
+// errorStatus returns status code and error code for errors of repository and decodeImage,
+// unknown errors are considered server faults.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, errInvalidSelection):
+		return http.StatusBadRequest, codeInvalidParams
+	case errors.Is(err, model.ErrNotFound):
+		return http.StatusNotFound, codeNotFound
+	case errors.Is(err, model.ErrConflict):
+		return http.StatusConflict, codeConflict
+	case errors.Is(err, errUnsupportedFormat), errors.Is(err, downloader.ErrUnsupportedContentType):
+		return http.StatusUnsupportedMediaType, codeUnsupported
+	case errors.Is(err, errCorruptImage):
+		return http.StatusUnprocessableEntity, codeCorruptImage
+	case errors.Is(err, errQuotaExceeded):
+		return http.StatusTooManyRequests, codeQuotaExceeded
+	case errors.Is(err, errTooManyPixels), errors.Is(err, downloader.ErrTooLarge):
+		return http.StatusUnprocessableEntity, codeImageTooLarge
+	case errors.Is(err, pool.ErrQueueFull), errors.Is(err, pool.ErrClosed):
+		return http.StatusServiceUnavailable, codeOverloaded
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable, codeCanceled
+	}
+	return http.StatusInternalServerError, codeInternal
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/imager/src/model"
+)
+
+// store runs fn, which saves images and notifies about them, in one transaction when the service has a transactor,
+// so events are written if and only if images are. fn gets context detached from cancellation of ctx,
+// since files are already uploaded and shouldn't be left without rows when the client goes away.
+func (s *Service) store(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx = detachedContext{ctx}
+	if s.tx == nil {
+		return fn(ctx)
+	}
+	return s.tx.InTx(ctx, fn)
+}
+
+// notify appends event about image of tenant of ctx to the log and adds its deliveries to the webhooks outbox.
+func (s *Service) notify(ctx context.Context, eventType string, img model.Image) error {
+	event := model.Event{
+		Type:      eventType,
+		TenantID:  model.TenantFromContext(ctx),
+		Image:     img,
+		CreatedAt: time.Now().UTC(),
+	}
+	if s.events != nil {
+		appended, err := s.events.Append(ctx, event)
+		if err != nil {
+			return fmt.Errorf("error appending event '%s' of image %d: %w", eventType, img.ID, err)
+		}
+		event = appended
+	}
+	if s.webhooks == nil {
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshaling event '%s' of image %d: %w", eventType, img.ID, err)
+	}
+	if err := s.webhooks.Publish(ctx, eventType, payload); err != nil {
+		return fmt.Errorf("error publishing event '%s' of image %d: %w", eventType, img.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	mock_model "github.com/imager/src/mock/model"
+	"github.com/imager/src/model"
+)
+
+func TestDelete(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	type tc struct {
+		name               string
+		id                 string
+		getTest            func() *Service
+		expectedStatusCode int
+	}
+
+	tcs := []tc{
+		{
+			name: "http.StatusBadRequest: invalid id",
+			id:   "one",
+			getTest: func() *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusNotFound",
+			id:   "1",
+			getTest: func() *Service {
+				repo := mock_model.NewMockImagesRepository(mockCtrl)
+				repo.EXPECT().Delete(gomock.Any(), 1).Return(nil, model.ErrNotFound)
+				return NewService(repo, nil, nil)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "http.StatusNoContent: events published for original and variants",
+			id:   "1",
+			getTest: func() *Service {
+				repo := mock_model.NewMockImagesRepository(mockCtrl)
+				repo.EXPECT().Delete(gomock.Any(), 1).Return([]model.Image{{ID: 1}, {ID: 2, OriginalID: 1}}, nil)
+				webhooks := mock_model.NewMockWebhooksRepository(mockCtrl)
+				for _, id := range []int{1, 2} {
+					id := id
+					webhooks.EXPECT().Publish(gomock.Any(), model.EventImageDeleted, gomock.Any()).DoAndReturn(func(_ interface{}, _ string, payload []byte) error {
+						var event model.Event
+						if err := json.Unmarshal(payload, &event); err != nil {
+							t.Fatal(err)
+						}
+						if event.Image.ID != id || event.TenantID != model.DefaultTenant {
+							t.Fatalf("unexpected event: %+v", event)
+						}
+						return nil
+					})
+				}
+				return NewService(repo, nil, nil, WithWebhooks(webhooks))
+			},
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name: "http.StatusNoContent: event appended to log before publishing",
+			id:   "1",
+			getTest: func() *Service {
+				repo := mock_model.NewMockImagesRepository(mockCtrl)
+				repo.EXPECT().Delete(gomock.Any(), 1).Return([]model.Image{{ID: 1}}, nil)
+				events := mock_model.NewMockEventsRepository(mockCtrl)
+				events.EXPECT().Append(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, event model.Event) (model.Event, error) {
+					event.ID = 42
+					return event, nil
+				})
+				webhooks := mock_model.NewMockWebhooksRepository(mockCtrl)
+				webhooks.EXPECT().Publish(gomock.Any(), model.EventImageDeleted, gomock.Any()).DoAndReturn(func(_ interface{}, _ string, payload []byte) error {
+					var event model.Event
+					if err := json.Unmarshal(payload, &event); err != nil {
+						t.Fatal(err)
+					}
+					if event.ID != 42 {
+						t.Fatalf("expected event id is: 42 but got: %d", event.ID)
+					}
+					return nil
+				})
+				return NewService(repo, nil, nil, WithEvents(events), WithWebhooks(webhooks))
+			},
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name: "http.StatusInternalServerError: publish failure rolls back deletion",
+			id:   "1",
+			getTest: func() *Service {
+				repo := mock_model.NewMockImagesRepository(mockCtrl)
+				repo.EXPECT().Delete(gomock.Any(), 1).Return([]model.Image{{ID: 1}}, nil)
+				webhooks := mock_model.NewMockWebhooksRepository(mockCtrl)
+				webhooks.EXPECT().Publish(gomock.Any(), model.EventImageDeleted, gomock.Any()).Return(errors.New("error"))
+				tx := mock_model.NewMockTransactor(mockCtrl)
+				tx.EXPECT().InTx(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error {
+					return fn(ctx)
+				})
+				return NewService(repo, nil, nil, WithWebhooks(webhooks), WithTransactor(tx))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			r := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/v1/images/"+tc.id, nil), map[string]string{"id": tc.id})
+			tc.getTest().Delete(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/logging"
+	"github.com/imager/src/model"
+)
+
+// errInvalidSelection is returned when images to export are selected by invalid params.
+var errInvalidSelection = errors.New("invalid selection of images")
+
+func invalidSelection(format string, args ...interface{}) error {
+	return apierror.WithDetails(errInvalidSelection, format, args...)
+}
+
+const (
+	formatZip   = "zip"
+	formatTarGz = "tar.gz"
+
+	// maxExportImages limits number of images in one archive.
+	maxExportImages = 1000
+	// manifestName is a name of the manifest in the archive.
+	manifestName = "manifest.json"
+)
+
+// ExportManifest describes exported images, it's the last file of the archive.
+type ExportManifest struct {
+	CreatedAt time.Time
+	Images    []ExportedImage
+}
+
+// ExportedImage describes image in the archive. Lineage is described by OriginalID of variants
+// and Variants of originals, Error is set instead of Path when the file couldn't be fetched.
+type ExportedImage struct {
+	model.Image
+	Path     string `json:",omitempty"`
+	Variants []int  `json:",omitempty"`
+	Error    string `json:",omitempty"`
+}
+
+// Export streams archive of images selected by ids, by original id along with its variants, or by filter.
+func (s *Service) Export(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	format, err := validateExportFormat(r)
+	if err != nil {
+		data, statusCode := errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "error validating format param", err)
+		response(w, data, statusCode)
+		return
+	}
+	images, err := s.exportedImages(ctx, r)
+	if err != nil {
+		statusCode, code := errorStatus(err)
+		data, statusCode := errorResponse(w, r, statusCode, code, "error selecting images", err)
+		response(w, data, statusCode)
+		return
+	}
+
+	aw, contentType := newArchiveWriter(w, format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%s.%s"`, time.Now().UTC().Format("20060102T150405Z"), format))
+	w.WriteHeader(http.StatusOK)
+
+	// the status is already sent, so failures of separate files are only recorded in the manifest.
+	// Failed writes mean the client is gone, so the export stops.
+	logger := logging.FromContext(ctx)
+	manifest := ExportManifest{CreatedAt: time.Now().UTC(), Images: images}
+	for i := range manifest.Images {
+		img := &manifest.Images[i]
+		b, err := s.fetchFile(ctx, img.DownloadURL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.ErrorContext(ctx, "error exporting image", "image_id", img.ID, "error", err)
+			img.Error = "couldn't fetch image"
+			continue
+		}
+		p := exportPath(img.Image)
+		if err := aw.store(p, b); err != nil {
+			logger.WarnContext(ctx, "error writing export archive", "error", err)
+			return
+		}
+		img.Path = p
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		logger.ErrorContext(ctx, "error marshaling export manifest", "error", err)
+		return
+	}
+	if err := aw.add(manifestName, b); err != nil {
+		logger.ErrorContext(ctx, "error writing export manifest", "error", err)
+		return
+	}
+	if err := aw.Close(); err != nil {
+		logger.ErrorContext(ctx, "error closing export archive", "error", err)
+	}
+}
+
+// exportedImages returns images selected by request params, originals come before their variants.
+func (s *Service) exportedImages(ctx context.Context, r *http.Request) ([]ExportedImage, error) {
+	q := r.URL.Query()
+	ids, originalID := q.Get("ids"), q.Get("original_id")
+	var images []model.Image
+	switch {
+	case ids != "" && originalID != "":
+		return nil, invalidSelection("only one of ids and original_id params can be set")
+	case ids != "":
+		// the limit is checked before fetching, since every id is fetched separately.
+		// repeated ids are exported once.
+		var selected []int
+		seen := make(map[int]bool)
+		for _, v := range strings.Split(ids, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return nil, invalidSelection("invalid id '%s' in ids param", v)
+			}
+			if !seen[id] {
+				seen[id] = true
+				selected = append(selected, id)
+			}
+		}
+		if len(selected) > maxExportImages {
+			return nil, tooManyImages(len(selected))
+		}
+		for _, id := range selected {
+			img, err := s.repo.GetOne(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get image by id: %d: %w", id, err)
+			}
+			images = append(images, img)
+		}
+	case originalID != "":
+		id, err := strconv.Atoi(originalID)
+		if err != nil {
+			return nil, invalidSelection("invalid original_id param '%s'", originalID)
+		}
+		original, err := s.repo.GetOne(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get image by id: %d: %w", id, err)
+		}
+		variants, err := s.repo.Variants(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get variants of image %d: %w", id, err)
+		}
+		images = append([]model.Image{original}, variants...)
+	default:
+		filter, err := parseImageFilter(r)
+		if err != nil {
+			return nil, invalidSelection("%v", err)
+		}
+		// pairs of originals with variants leave out originals without variants, so originals are listed separately.
+		originals, err := s.repo.Originals(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("error getting original images from db: %w", err)
+		}
+		all, err := s.repo.All(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("error getting images from db: %w", err)
+		}
+		variants := make(map[int][]model.Image)
+		for _, pair := range all {
+			pair.Resized.OriginalID = pair.Original.ID
+			variants[pair.Original.ID] = append(variants[pair.Original.ID], pair.Resized)
+		}
+		for _, original := range originals {
+			images = append(images, original)
+			images = append(images, variants[original.ID]...)
+		}
+	}
+	if len(images) > maxExportImages {
+		return nil, tooManyImages(len(images))
+	}
+
+	res := make([]ExportedImage, len(images))
+	originals := make(map[int]int)
+	for i, img := range images {
+		res[i] = ExportedImage{Image: img}
+		if img.OriginalID == 0 {
+			originals[img.ID] = i
+		}
+	}
+	for _, img := range images {
+		if i, ok := originals[img.OriginalID]; ok {
+			res[i].Variants = append(res[i].Variants, img.ID)
+		}
+	}
+	return res, nil
+}
+
+func tooManyImages(n int) error {
+	return invalidSelection("at most %d images can be exported at once, %d are selected", maxExportImages, n)
+}
+
+// fetchFile reads whole file of image through the downloader, which limits its size,
+// so files failing mid-stream aren't added to the archive truncated.
+func (s *Service) fetchFile(ctx context.Context, url string) ([]byte, error) {
+	body, err := s.downloader.Open(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// exportPath groups originals with their variants, e.g. 1/1-640x480.jpg and 1/2-100x100.png.
+func exportPath(img model.Image) string {
+	dir := img.ID
+	if img.OriginalID != 0 {
+		dir = img.OriginalID
+	}
+	return fmt.Sprintf("%d/%d-%s%s", dir, img.ID, img.Resolution, path.Ext(img.DownloadURL))
+}
+
+func validateExportFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "", formatZip:
+		return formatZip, nil
+	case formatTarGz:
+		return formatTarGz, nil
+	default:
+		return "", fmt.Errorf("invalid format '%s', expected one of: %s, %s", format, formatZip, formatTarGz)
+	}
+}
+
+// archiveWriter writes files to zip or tar.gz archive.
+type archiveWriter interface {
+	// store adds file which is already compressed.
+	store(name string, b []byte) error
+	add(name string, b []byte) error
+	Close() error
+}
+
+func newArchiveWriter(w io.Writer, format string) (archiveWriter, string) {
+	if format == formatTarGz {
+		gw := gzip.NewWriter(w)
+		return &tarGzWriter{gw: gw, tw: tar.NewWriter(gw)}, "application/gzip"
+	}
+	return &zipWriter{zip.NewWriter(w)}, "application/zip"
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (a *zipWriter) store(name string, b []byte) error {
+	return a.write(name, b, zip.Store)
+}
+
+func (a *zipWriter) add(name string, b []byte) error {
+	return a.write(name, b, zip.Deflate)
+}
+
+func (a *zipWriter) write(name string, b []byte, method uint16) error {
+	w, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (a *zipWriter) Close() error {
+	return a.zw.Close()
+}
+
+type tarGzWriter struct {
+	gw *gzip.Writer
+	tw *tar.Writer
+}
+
+// store adds file the same way as add, the whole archive is compressed.
+func (a *tarGzWriter) store(name string, b []byte) error {
+	return a.add(name, b)
+}
+
+func (a *tarGzWriter) add(name string, b []byte) error {
+	if err := a.tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(b)), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	_, err := a.tw.Write(b)
+	return err
+}
+
+func (a *tarGzWriter) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gw.Close()
+}
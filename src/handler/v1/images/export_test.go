@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/golang/mock/gomock"
+	mock_downloader "github.com/imager/src/mock/downloader"
+	mock_model "github.com/imager/src/mock/model"
+	"github.com/imager/src/model"
+)
+
+func TestExport(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	original := model.Image{ID: 1, DownloadURL: "http://images/default/a.jpg", Resolution: "640x480"}
+	variants := []model.Image{
+		{ID: 2, DownloadURL: "http://images/default/b.png", Resolution: "100x100", OriginalID: 1},
+		{ID: 3, DownloadURL: "http://images/default/c.png", Resolution: "200x200", OriginalID: 1},
+	}
+
+	type tc struct {
+		name               string
+		query              string
+		getTest            func() *Service
+		expectedStatusCode int
+		expectedFiles      map[string]string
+		expectedManifest   func(t *testing.T, m ExportManifest)
+	}
+
+	tcs := []tc{
+		{
+			name:  "http.StatusBadRequest: unknown format",
+			query: "format=rar",
+			getTest: func() *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:  "http.StatusBadRequest: ids and original id",
+			query: "ids=1&original_id=1",
+			getTest: func() *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:  "http.StatusNotFound: unknown id",
+			query: "ids=1,7",
+			getTest: func() *Service {
+				repo := mock_model.NewMockImagesRepository(mockCtrl)
+				repo.EXPECT().GetOne(gomock.Any(), 1).Return(original, nil)
+				repo.EXPECT().GetOne(gomock.Any(), 7).Return(model.Image{}, model.ErrNotFound)
+				return NewService(repo, nil, nil)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:  "http.StatusBadRequest: too many ids",
+			query: "ids=" + distinctIDs(maxExportImages+1),
+			getTest: func() *Service {
+				return NewService(mock_model.NewMockImagesRepository(mockCtrl), nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:  "http.StatusOK: filter keeps originals without variants",
+			query: "",
+			getTest: func() *Service {
+				single := model.Image{ID: 4, DownloadURL: "http://images/default/d.jpg", Resolution: "300x300"}
+				repo := mock_model.NewMockImagesRepository(mockCtrl)
+				repo.EXPECT().Originals(gomock.Any(), model.ImageFilter{}).Return([]model.Image{original, single}, nil)
+				repo.EXPECT().All(gomock.Any(), model.ImageFilter{}).Return([]model.OriginalResized{{Original: original, Resized: variants[0]}}, nil)
+				downloadSvc := mock_downloader.NewMockService(mockCtrl)
+				downloadSvc.EXPECT().Open(gomock.Any(), original.DownloadURL).Return(ioutil.NopCloser(bytes.NewReader([]byte("a"))), nil)
+				downloadSvc.EXPECT().Open(gomock.Any(), variants[0].DownloadURL).Return(ioutil.NopCloser(bytes.NewReader([]byte("b"))), nil)
+				downloadSvc.EXPECT().Open(gomock.Any(), single.DownloadURL).Return(ioutil.NopCloser(bytes.NewReader([]byte("d"))), nil)
+				return NewService(repo, nil, downloadSvc)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedFiles:      map[string]string{"1/1-640x480.jpg": "a", "1/2-100x100.png": "b", "4/4-300x300.jpg": "d"},
+			expectedManifest: func(t *testing.T, m ExportManifest) {
+				if len(m.Images) != 3 || m.Images[1].OriginalID != 1 || m.Images[2].ID != 4 || len(m.Images[2].Variants) != 0 {
+					t.Fatalf("unexpected manifest: %+v", m)
+				}
+			},
+		},
+		{
+			name:  "http.StatusOK: zip of original with variants",
+			query: "original_id=1",
+			getTest: func() *Service {
+				repo := mock_model.NewMockImagesRepository(mockCtrl)
+				repo.EXPECT().GetOne(gomock.Any(), 1).Return(original, nil)
+				repo.EXPECT().Variants(gomock.Any(), 1).Return(variants, nil)
+				downloadSvc := mock_downloader.NewMockService(mockCtrl)
+				downloadSvc.EXPECT().Open(gomock.Any(), original.DownloadURL).Return(ioutil.NopCloser(bytes.NewReader([]byte("a"))), nil)
+				downloadSvc.EXPECT().Open(gomock.Any(), variants[0].DownloadURL).Return(ioutil.NopCloser(bytes.NewReader([]byte("b"))), nil)
+				downloadSvc.EXPECT().Open(gomock.Any(), variants[1].DownloadURL).Return(nil, errors.New("error"))
+				return NewService(repo, nil, downloadSvc)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedFiles:      map[string]string{"1/1-640x480.jpg": "a", "1/2-100x100.png": "b"},
+			expectedManifest: func(t *testing.T, m ExportManifest) {
+				if len(m.Images) != 3 {
+					t.Fatalf("expected 3 images in manifest but got: %d", len(m.Images))
+				}
+				if v := m.Images[0].Variants; len(v) != 2 || v[0] != 2 || v[1] != 3 {
+					t.Fatalf("unexpected variants of original: %v", v)
+				}
+				if m.Images[2].Error == "" || m.Images[2].Path != "" {
+					t.Fatalf("expected failed image without path but got: %+v", m.Images[2])
+				}
+			},
+		},
+		{
+			name:  "http.StatusOK: repeated ids and file failing mid-stream",
+			query: "ids=2,3,2",
+			getTest: func() *Service {
+				repo := mock_model.NewMockImagesRepository(mockCtrl)
+				repo.EXPECT().GetOne(gomock.Any(), 2).Return(variants[0], nil)
+				repo.EXPECT().GetOne(gomock.Any(), 3).Return(variants[1], nil)
+				downloadSvc := mock_downloader.NewMockService(mockCtrl)
+				downloadSvc.EXPECT().Open(gomock.Any(), variants[0].DownloadURL).Return(ioutil.NopCloser(bytes.NewReader([]byte("b"))), nil)
+				broken := io.MultiReader(bytes.NewReader([]byte("c")), iotest.ErrReader(errors.New("connection reset")))
+				downloadSvc.EXPECT().Open(gomock.Any(), variants[1].DownloadURL).Return(ioutil.NopCloser(broken), nil)
+				return NewService(repo, nil, downloadSvc)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedFiles:      map[string]string{"1/2-100x100.png": "b"},
+			expectedManifest: func(t *testing.T, m ExportManifest) {
+				if len(m.Images) != 2 || m.Images[1].Error == "" || m.Images[1].Path != "" {
+					t.Fatalf("unexpected manifest: %+v", m)
+				}
+			},
+		},
+		{
+			name:  "http.StatusOK: tar.gz by ids",
+			query: "format=tar.gz&ids=2",
+			getTest: func() *Service {
+				repo := mock_model.NewMockImagesRepository(mockCtrl)
+				repo.EXPECT().GetOne(gomock.Any(), 2).Return(variants[0], nil)
+				downloadSvc := mock_downloader.NewMockService(mockCtrl)
+				downloadSvc.EXPECT().Open(gomock.Any(), variants[0].DownloadURL).Return(ioutil.NopCloser(bytes.NewReader([]byte("b"))), nil)
+				return NewService(repo, nil, downloadSvc)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedFiles:      map[string]string{"1/2-100x100.png": "b"},
+			expectedManifest: func(t *testing.T, m ExportManifest) {
+				if len(m.Images) != 1 || m.Images[0].OriginalID != 1 || m.Images[0].Path != "1/2-100x100.png" {
+					t.Fatalf("unexpected manifest: %+v", m)
+				}
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/export?"+tc.query, nil)
+			tc.getTest().Export(wr, r)
+
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+			if statusCode != http.StatusOK {
+				return
+			}
+
+			files, err := readArchive(wr.Body.Bytes(), wr.Header().Get("Content-Type"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var manifest ExportManifest
+			if err := json.Unmarshal([]byte(files[manifestName]), &manifest); err != nil {
+				t.Fatal(err)
+			}
+			delete(files, manifestName)
+			if len(files) != len(tc.expectedFiles) {
+				t.Fatalf("expected files are: %v but got: %v", tc.expectedFiles, files)
+			}
+			for name, content := range tc.expectedFiles {
+				if files[name] != content {
+					t.Fatalf("expected content of %s is: %s but got: %s", name, content, files[name])
+				}
+			}
+			tc.expectedManifest(t, manifest)
+		})
+	}
+}
+
+// readArchive returns contents of files of zip or tar.gz archive by their names.
+func readArchive(b []byte, contentType string) (map[string]string, error) {
+	files := make(map[string]string)
+	if contentType == "application/zip" {
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			content, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			files[f.Name] = string(content)
+		}
+		return files, nil
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[h.Name] = string(content)
+	}
+}
+
+// failingWriter fails every write like a connection of a client which is gone.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestExportStopsWhenClientIsGone(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	images := []model.Image{
+		{ID: 1, DownloadURL: "http://images/default/a.jpg", Resolution: "640x480"},
+		{ID: 2, DownloadURL: "http://images/default/b.jpg", Resolution: "640x480"},
+	}
+	repo := mock_model.NewMockImagesRepository(mockCtrl)
+	repo.EXPECT().GetOne(gomock.Any(), 1).Return(images[0], nil)
+	repo.EXPECT().GetOne(gomock.Any(), 2).Return(images[1], nil)
+	downloadSvc := mock_downloader.NewMockService(mockCtrl)
+	// only the first image is downloaded, writing it fails.
+	downloadSvc.EXPECT().Open(gomock.Any(), images[0].DownloadURL).Return(ioutil.NopCloser(bytes.NewReader(bytes.Repeat([]byte("a"), 1<<16))), nil)
+
+	w := failingWriter{httptest.NewRecorder()}
+	NewService(repo, nil, downloadSvc).Export(w, httptest.NewRequest(http.MethodGet, "/api/v1/export?ids=1,2", nil))
+}
+
+// distinctIDs returns value of ids param with n different ids.
+func distinctIDs(n int) string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i + 1)
+	}
+	return strings.Join(ids, ",")
+}
@@ -15,213 +15,405 @@ import (
 	"sync"
 
 	"github.com/gorilla/mux"
+	"github.com/imager/src/config"
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/imgproc/palette"
+	"github.com/imager/src/logging"
 	"github.com/imager/src/model"
+	"github.com/imager/src/pool"
 	"github.com/imager/src/web/downloader"
 	"github.com/imager/src/web/uploader"
 
 	"github.com/disintegration/imaging"
 )
 
-const imgFormat = imaging.PNG
+const (
+	imgFormat = imaging.PNG
+	// paletteSize is a number of colors extracted for every image.
+	paletteSize = 5
+	// nearColorPrefix precedes color in the filter by dominant color, e.g. color=near:#ff0000.
+	nearColorPrefix = "near:"
+	// defaultSimilarDistance is a Hamming distance used for similar images search when it's not specified.
+	defaultSimilarDistance = 10
+	maxSimilarDistance     = 64
+)
 
 // Service represents handler service.
 type Service struct {
 	repo       model.ImagesRepository
 	uploader   uploader.Service
 	downloader downloader.Service
+	limits     config.Limits
+	quotas     model.QuotasRepository
+	pool       *pool.Pool
+	jobs       model.JobsRepository
+	webhooks   model.WebhooksRepository
+	events     model.EventsRepository
+	tx         model.Transactor
+	// resizes coalesces identical concurrent ResizeByID requests.
+	resizes coalescer
+}
+
+// Option configures handler service.
+type Option func(*Service)
+
+// WithLimits sets sizes of images the service agrees to process.
+func WithLimits(limits config.Limits) Option {
+	return func(s *Service) {
+		s.limits = limits
+	}
+}
+
+// WithQuotas enables enforcement of tenant quotas before images are uploaded.
+func WithQuotas(quotas model.QuotasRepository) Option {
+	return func(s *Service) {
+		s.quotas = quotas
+	}
+}
+
+// WithPool makes the service process images on the pool instead of goroutines of requests.
+func WithPool(p *pool.Pool) Option {
+	return func(s *Service) {
+		s.pool = p
+	}
+}
+
+// WithJobs enables asynchronous resizes queued to jobs.
+func WithJobs(jobs model.JobsRepository) Option {
+	return func(s *Service) {
+		s.jobs = jobs
+	}
+}
+
+// WithWebhooks makes the service publish image lifecycle events to webhooks.
+func WithWebhooks(webhooks model.WebhooksRepository) Option {
+	return func(s *Service) {
+		s.webhooks = webhooks
+	}
+}
+
+// WithEvents makes the service append image lifecycle events to the log.
+func WithEvents(events model.EventsRepository) Option {
+	return func(s *Service) {
+		s.events = events
+	}
+}
+
+// WithTransactor makes the service store images together with their events in one transaction.
+func WithTransactor(tx model.Transactor) Option {
+	return func(s *Service) {
+		s.tx = tx
+	}
 }
 
 // NewService returns new handler service.
-func NewService(repo model.ImagesRepository, uploader uploader.Service, downloader downloader.Service) *Service {
-	return &Service{repo: repo, uploader: uploader, downloader: downloader}
+func NewService(repo model.ImagesRepository, uploader uploader.Service, downloader downloader.Service, opts ...Option) *Service {
+	s := &Service{repo: repo, uploader: uploader, downloader: downloader, limits: config.DefaultLimits}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // All returns all images.
 func (s *Service) All(w http.ResponseWriter, r *http.Request) {
 	data, statusCode := func() ([]byte, int) {
 		ctx := r.Context()
-		images, err := s.repo.All(ctx)
+		filter, err := parseImageFilter(r)
+		if err != nil {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "error validating filter params", err)
+		}
+		images, err := s.repo.All(ctx, filter)
 		if err != nil {
-			return []byte(fmt.Sprintf("error getting images from db: %v", err)),
-				http.StatusInternalServerError
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error getting images from db", err)
 		}
 		res, err := json.Marshal(images)
 		if err != nil {
-			return []byte(fmt.Sprintf("error during marshaling images: %v", err)),
-				http.StatusInternalServerError
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error during marshaling images", err)
 		}
 		return res, http.StatusOK
 	}()
 	response(w, data, statusCode)
 }
 
-// ResizeByID uses for changing existing image.
-func (s *Service) ResizeByID(w http.ResponseWriter, r *http.Request) {
-	data, statusCode := func(w http.ResponseWriter, r *http.Request) ([]byte, int) {
+// GetByID returns specific image.
+func (s *Service) GetByID(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func() ([]byte, int) {
 		ctx := r.Context()
-		weight, height, err := validateSizeParams(r)
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
 		if err != nil {
-			return []byte(fmt.Sprintf("error validating resize params: %v", err)),
-				http.StatusBadRequest
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidID, "error converting id to int", err)
 		}
-		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		logging.AddAttrs(ctx, "image_id", id)
+		image, err := s.repo.GetOne(ctx, id)
 		if err != nil {
-			return []byte(fmt.Sprintf("error converting id to int: %v", err)),
-				http.StatusBadRequest
+			statusCode, code := errorStatus(err)
+			return errorResponse(w, r, statusCode, code, fmt.Sprintf("couldn't get image by id: %d", id), err)
 		}
-		originalImage, err := s.repo.GetOne(ctx, id)
+		res, err := json.Marshal(image)
 		if err != nil {
-			return []byte(fmt.Sprintf("couldn't get image by id: %d with error: %v", id, err)),
-				http.StatusInternalServerError
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error during marshaling image", err)
 		}
+		return res, http.StatusOK
+	}()
+	response(w, data, statusCode)
+}
 
-		originalImageName := path.Base(originalImage.DownloadURL)
-
-		oldImgBytes, err := s.downloader.Download(ctx, originalImage.DownloadURL)
+// Similar returns originals which look similar to specific image.
+func (s *Service) Similar(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func() ([]byte, int) {
+		ctx := r.Context()
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
 		if err != nil {
-			return []byte(fmt.Sprintf("couldn't download image by url: %s with error: %v", originalImageName, err)),
-				http.StatusInternalServerError
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidID, "error converting id to int", err)
 		}
-
-		newImageResolution := fmt.Sprintf("%dx%d", weight, height)
-
-		img, err := imaging.Decode(bytes.NewReader(oldImgBytes))
+		logging.AddAttrs(ctx, "image_id", id)
+		distance, err := validateDistanceParam(r)
 		if err != nil {
-			return []byte(fmt.Sprintf("error decoding file %s into image: %v", originalImageName, err)),
-				http.StatusInternalServerError
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "error validating distance param", err)
 		}
-
-		img = imaging.Resize(img, weight, height, imaging.NearestNeighbor)
-		if img == nil {
-			return []byte(fmt.Sprintf("couldn't resize image '%s'", originalImageName)),
-				http.StatusInternalServerError
+		image, err := s.repo.GetOne(ctx, id)
+		if err != nil {
+			statusCode, code := errorStatus(err)
+			return errorResponse(w, r, statusCode, code, fmt.Sprintf("couldn't get image by id: %d", id), err)
 		}
-
-		buf := new(bytes.Buffer)
-		if err := imaging.Encode(buf, img, imgFormat); err != nil {
-			return []byte(fmt.Sprintf("error encoding file %s to buffer: %v", originalImageName, err)),
-				http.StatusInternalServerError
+		if image.PerceptualHash == "" {
+			return errorResponse(w, r, http.StatusUnprocessableEntity, codeUnprocessable, fmt.Sprintf("image %d has no perceptual hash, only originals can be compared", id), nil)
 		}
-
-		hash, err := calculateMD5(bytes.NewBuffer(buf.Bytes()))
+		images, err := s.repo.Similar(ctx, id, distance)
 		if err != nil {
-			return []byte(fmt.Sprintf("error calculating md5 for image %v", err)),
-				http.StatusInternalServerError
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error getting similar images from db", err)
 		}
-
-		downloadURL, err := s.uploader.Upload(ctx, name(hash), buf)
+		res, err := json.Marshal(images)
 		if err != nil {
-			return []byte(fmt.Sprintf("error downloading image %v", err)),
-				http.StatusInternalServerError
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error during marshaling images", err)
 		}
+		return res, http.StatusOK
+	}()
+	response(w, data, statusCode)
+}
 
-		newImage := model.Image{
-			DownloadURL: downloadURL,
-			Resolution:  newImageResolution,
-			OriginalID:  originalImage.ID,
+// ResizeByID uses for changing existing image.
+func (s *Service) ResizeByID(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func(w http.ResponseWriter, r *http.Request) ([]byte, int) {
+		ctx := r.Context()
+		weight, height, err := validateSizeParams(r)
+		if err != nil {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "error validating resize params", err)
 		}
-
-		id, err = s.repo.Save(ctx, newImage)
+		opts, err := validateResizeOptions(r)
 		if err != nil {
-			return []byte(err.Error()),
-				http.StatusInternalServerError
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "error validating resize params", err)
+		}
+		if err := validateOutputSize(weight, height, s.limits); err != nil {
+			return errorResponse(w, r, http.StatusUnprocessableEntity, codeInvalidParams, "error validating resize params", err)
+		}
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidID, "error converting id to int", err)
+		}
+		logging.AddAttrs(ctx, "image_id", id)
+		async, err := validateAsyncParam(r)
+		if err != nil {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "error validating async param", err)
+		}
+		if async {
+			return s.enqueueResize(w, r, model.ResizeParams{ImageID: id, Width: weight, Height: height, Mode: opts.mode, Gravity: opts.gravity})
 		}
-		newImage.ID = id
 
-		res := model.OriginalResized{
-			Original: originalImage,
-			Resized:  newImage,
+		v, err := s.resizes.do(ctx, resizeKey(ctx, id, weight, height, opts), func(ctx context.Context) (interface{}, error) {
+			return s.resizeByID(ctx, id, weight, height, opts)
+		})
+		if err != nil {
+			statusCode, code := errorStatus(err)
+			return errorResponse(w, r, statusCode, code, fmt.Sprintf("error resizing image %d", id), err)
 		}
+		logging.AddAttrs(ctx, "resized_image_id", v.(model.OriginalResized).Resized.ID)
 
-		b, err := json.Marshal(res)
+		b, err := json.Marshal(v.(model.OriginalResized))
 		if err != nil {
-			return []byte(fmt.Sprintf("error marshaling result: %v", err)),
-				http.StatusInternalServerError
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error marshaling result", err)
 		}
 		return b, http.StatusCreated
 	}(w, r)
 	response(w, data, statusCode)
 }
 
+// resizeKey identifies resizes of stored image which are coalesced.
+func resizeKey(ctx context.Context, id, weight, height int, opts resizeOptions) string {
+	return fmt.Sprintf("%s/%d/%dx%d/%s/%s", model.TenantFromContext(ctx), id, weight, height, opts.mode, opts.gravity)
+}
+
+// resizeByID resizes stored image and saves the result as its variant.
+func (s *Service) resizeByID(ctx context.Context, id, weight, height int, opts resizeOptions) (model.OriginalResized, error) {
+	originalImage, err := s.repo.GetOne(ctx, id)
+	if err != nil {
+		return model.OriginalResized{}, fmt.Errorf("couldn't get image by id: %d: %w", id, err)
+	}
+
+	originalImageName := path.Base(originalImage.DownloadURL)
+
+	oldImgBytes, err := s.downloader.Download(ctx, originalImage.DownloadURL)
+	if err != nil {
+		return model.OriginalResized{}, fmt.Errorf("couldn't download image by url: %s: %w", originalImageName, err)
+	}
+
+	resized, err := s.processVariant(ctx, oldImgBytes, weight, height, opts)
+	if err != nil {
+		return model.OriginalResized{}, fmt.Errorf("error processing image %s: %w", originalImageName, err)
+	}
+
+	hash, err := calculateMD5(bytes.NewBuffer(resized.encoded))
+	if err != nil {
+		return model.OriginalResized{}, err
+	}
+
+	size := int64(len(resized.encoded))
+	if err := s.checkQuota(ctx, 1, size); err != nil {
+		return model.OriginalResized{}, err
+	}
+
+	downloadURL, err := s.uploader.Upload(ctx, name(hash), bytes.NewBuffer(resized.encoded))
+	if err != nil {
+		return model.OriginalResized{}, fmt.Errorf("error uploading image: %w", err)
+	}
+
+	newImage := model.Image{
+		DownloadURL:   downloadURL,
+		Resolution:    fmt.Sprintf("%dx%d", weight, height),
+		OriginalID:    originalImage.ID,
+		DominantColor: palette.Hex(resized.colors.Dominant),
+		Palette:       hexColors(resized.colors),
+		Size:          size,
+	}
+
+	err = s.store(ctx, func(ctx context.Context) error {
+		if err := s.reserveQuota(ctx, 1, size); err != nil {
+			return err
+		}
+		newImage.ID, err = s.repo.Save(ctx, newImage)
+		if err != nil {
+			return fmt.Errorf("error saving image: %w", err)
+		}
+		return s.notify(ctx, model.EventImageResized, newImage)
+	})
+	if err != nil {
+		return model.OriginalResized{}, err
+	}
+
+	return model.OriginalResized{
+		Original: originalImage,
+		Resized:  newImage,
+	}, nil
+}
+
 // Resize creates and resizes image.
 func (s *Service) Resize(w http.ResponseWriter, r *http.Request) {
 	data, statusCode := func(w http.ResponseWriter, r *http.Request) ([]byte, int) {
 		ctx := r.Context()
 		weight, height, err := validateSizeParams(r)
 		if err != nil {
-			return []byte(fmt.Sprintf("error validating resize params: %v", err)),
-				http.StatusBadRequest
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "error validating resize params", err)
+		}
+		opts, err := validateResizeOptions(r)
+		if err != nil {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "error validating resize params", err)
+		}
+		if err := validateOutputSize(weight, height, s.limits); err != nil {
+			return errorResponse(w, r, http.StatusUnprocessableEntity, codeInvalidParams, "error validating resize params", err)
+		}
+
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, s.limits.MaxBodyBytes)
 		}
 
 		file, h, err := r.FormFile("file")
+		if apierror.IsBodyTooLarge(err) {
+			return errorResponse(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge, fmt.Sprintf("request body is too large, at most %d bytes are allowed", s.limits.MaxBodyBytes), nil)
+		}
 		if err != nil {
-			return []byte(fmt.Sprintf("error decoding file into image: %v", err)),
-				http.StatusBadRequest
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidBody, "error decoding file into image", err)
 		}
 		defer file.Close()
 
 		oldImgBytes, err := ioutil.ReadAll(file)
+		if apierror.IsBodyTooLarge(err) {
+			return errorResponse(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge, fmt.Sprintf("request body is too large, at most %d bytes are allowed", s.limits.MaxBodyBytes), nil)
+		}
 		if err != nil {
-			return []byte(fmt.Sprintf("error reading file %s with error: %v", h.Filename, err)),
-				http.StatusBadRequest
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidBody, fmt.Sprintf("error reading file %s", h.Filename), err)
 		}
 
-		img, err := imaging.Decode(bytes.NewReader(oldImgBytes))
+		res, err := s.resize(ctx, oldImgBytes, weight, height, opts)
 		if err != nil {
-			return []byte(fmt.Sprintf("error decoding file %s into image: %v", h.Filename, err)),
-				http.StatusInternalServerError
+			statusCode, code := errorStatus(err)
+			return errorResponse(w, r, statusCode, code, fmt.Sprintf("error resizing file %s", h.Filename), err)
 		}
+		logging.AddAttrs(ctx, "image_id", res.Original.ID, "resized_image_id", res.Resized.ID)
 
-		originalImageResolution := fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy())
-
-		img = imaging.Resize(img, weight, height, imaging.NearestNeighbor)
-		if img == nil {
-			return []byte(fmt.Sprintf("couldn't resize image '%s'", h.Filename)),
-				http.StatusInternalServerError
+		b, err := json.Marshal(res)
+		if err != nil {
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error marshaling result", err)
 		}
+		return b, http.StatusCreated
+	}(w, r)
+	response(w, data, statusCode)
+}
 
-		buf := new(bytes.Buffer)
-		if err := imaging.Encode(buf, img, imgFormat); err != nil {
-			return []byte(fmt.Sprintf("error encoding file %s to buffer: %v", h.Filename, err)),
-				http.StatusInternalServerError
-		}
+// resize stores new original image and its variant resized to weight x height.
+func (s *Service) resize(ctx context.Context, oldImgBytes []byte, weight, height int, opts resizeOptions) (model.OriginalResized, error) {
+	processed, err := s.processUpload(ctx, oldImgBytes, weight, height, opts)
+	if err != nil {
+		return model.OriginalResized{}, fmt.Errorf("error processing image: %w", err)
+	}
 
-		newImgBytes := buf.Bytes()
+	newImgBytes := processed.resized.encoded
 
-		res, err := s.uploadImages(ctx, [2][]byte{oldImgBytes, newImgBytes})
-		if err != nil {
-			return []byte(fmt.Sprintf("error uploading images: %v", err)),
-				http.StatusInternalServerError
-		}
+	if err := s.checkQuota(ctx, 2, int64(len(oldImgBytes)+len(newImgBytes))); err != nil {
+		return model.OriginalResized{}, err
+	}
 
-		res.Original.Resolution = originalImageResolution
-		res.Resized.Resolution = fmt.Sprintf("%dx%d", weight, height)
+	res, err := s.uploadImages(ctx, [2][]byte{oldImgBytes, newImgBytes})
+	if err != nil {
+		return model.OriginalResized{}, fmt.Errorf("error uploading images: %w", err)
+	}
 
-		originalID, err := s.repo.Save(ctx, res.Original)
-		if err != nil {
-			return []byte(err.Error()),
-				http.StatusInternalServerError
+	res.Original.Resolution = processed.resolution
+	res.Original.BlurHash = processed.placeholder.BlurHash
+	res.Original.LQIP = processed.placeholder.LQIP
+	res.Original.DominantColor = palette.Hex(processed.colors.Dominant)
+	res.Original.Palette = hexColors(processed.colors)
+	res.Original.PerceptualHash = hexHash(processed.hash)
+	res.Original.Size = int64(len(oldImgBytes))
+	res.Resized.Resolution = fmt.Sprintf("%dx%d", weight, height)
+	res.Resized.DominantColor = palette.Hex(processed.resized.colors.Dominant)
+	res.Resized.Palette = hexColors(processed.resized.colors)
+	res.Resized.Size = int64(len(newImgBytes))
+
+	err = s.store(ctx, func(ctx context.Context) error {
+		if err := s.reserveQuota(ctx, 2, res.Original.Size+res.Resized.Size); err != nil {
+			return err
 		}
-
-		res.Original.ID = originalID
-		res.Resized.OriginalID = originalID
-
-		resizedID, err := s.repo.Save(ctx, res.Resized)
+		res.Original.ID, err = s.repo.Save(ctx, res.Original)
 		if err != nil {
-			return []byte(err.Error()),
-				http.StatusInternalServerError
+			return fmt.Errorf("error saving image: %w", err)
+		}
+		res.Resized.OriginalID = res.Original.ID
+		if err := s.notify(ctx, model.EventImageCreated, res.Original); err != nil {
+			return err
 		}
 
-		res.Resized.ID = resizedID
-
-		b, err := json.Marshal(res)
+		res.Resized.ID, err = s.repo.Save(ctx, res.Resized)
 		if err != nil {
-			return []byte(fmt.Sprintf("error marshaling result: %v", err)),
-				http.StatusInternalServerError
+			return fmt.Errorf("error saving image: %w", err)
 		}
-		return b, http.StatusCreated
-	}(w, r)
-	response(w, data, statusCode)
+		return s.notify(ctx, model.EventImageResized, res.Resized)
+	})
+	if err != nil {
+		return model.OriginalResized{}, err
+	}
+
+	return res, nil
 }
 
 func calculateMD5(r io.Reader) (string, error) {
@@ -235,7 +427,11 @@ func calculateMD5(r io.Reader) (string, error) {
 }
 
 func name(hash string) string {
-	return fmt.Sprintf("%s.%s", hash, strings.ToLower(imgFormat.String()))
+	return fileName(hash, imgFormat)
+}
+
+func fileName(hash string, format imaging.Format) string {
+	return fmt.Sprintf("%s.%s", hash, strings.ToLower(format.String()))
 }
 
 func (s *Service) uploadImages(ctx context.Context, images [2][]byte) (model.OriginalResized, error) {
@@ -286,19 +482,51 @@ func (s *Service) uploadImages(ctx context.Context, images [2][]byte) (model.Ori
 	return res, nil
 }
 
+// Delete deletes image along with its variants. Stored files are kept since other images may share them.
+func (s *Service) Delete(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func() ([]byte, int) {
+		ctx := r.Context()
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidID, "error converting id to int", err)
+		}
+		logging.AddAttrs(ctx, "image_id", id)
+		err = s.store(ctx, func(ctx context.Context) error {
+			deleted, err := s.repo.Delete(ctx, id)
+			if err != nil {
+				return err
+			}
+			for _, img := range deleted {
+				if err := s.notify(ctx, model.EventImageDeleted, img); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			statusCode, code := errorStatus(err)
+			return errorResponse(w, r, statusCode, code, fmt.Sprintf("error deleting image %d", id), err)
+		}
+		return nil, http.StatusNoContent
+	}()
+	response(w, data, statusCode)
+}
+
 // OnlyResized returns only resized images.
 func (s *Service) OnlyResized(w http.ResponseWriter, r *http.Request) {
 	data, statusCode := func() ([]byte, int) {
 		ctx := r.Context()
-		images, err := s.repo.OnlyResized(ctx)
+		filter, err := parseImageFilter(r)
 		if err != nil {
-			return []byte(fmt.Sprintf("error getting resized images from db: %v", err)),
-				http.StatusInternalServerError
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "error validating filter params", err)
+		}
+		images, err := s.repo.OnlyResized(ctx, filter)
+		if err != nil {
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error getting resized images from db", err)
 		}
 		res, err := json.Marshal(images)
 		if err != nil {
-			return []byte(fmt.Sprintf("error during marshaling images: %v", err)),
-				http.StatusInternalServerError
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error during marshaling images", err)
 		}
 		return res, http.StatusOK
 	}()
@@ -306,11 +534,66 @@ func (s *Service) OnlyResized(w http.ResponseWriter, r *http.Request) {
 }
 
 func response(w http.ResponseWriter, data []byte, statusCode int) {
-	w.Header().Add("Conent-Type", "application/json")
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	w.Write(data)
 }
 
+func hexColors(p palette.Palette) []string {
+	res := make([]string, 0, len(p.Colors))
+	for _, c := range p.Colors {
+		res = append(res, palette.Hex(c))
+	}
+	return res
+}
+
+// parseImageFilter parses images filter from query, color should be url encoded e.g. color=near:%23ff0000.
+func parseImageFilter(r *http.Request) (model.ImageFilter, error) {
+	var filter model.ImageFilter
+	if c := r.URL.Query().Get("color"); c != "" {
+		if !strings.HasPrefix(c, nearColorPrefix) {
+			return model.ImageFilter{}, fmt.Errorf("invalid color filter '%s', expected format is near:#rrggbb", c)
+		}
+		nearColor, err := palette.ParseHex(strings.TrimPrefix(c, nearColorPrefix))
+		if err != nil {
+			return model.ImageFilter{}, err
+		}
+		filter.NearColor = &nearColor
+	}
+	return filter, nil
+}
+
+func hexHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+func validateDistanceParam(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("distance")
+	if v == "" {
+		return defaultSimilarDistance, nil
+	}
+	d, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid distance param")
+	}
+	if d < 0 || d > maxSimilarDistance {
+		return 0, fmt.Errorf("distance should be between 0 and %d", maxSimilarDistance)
+	}
+	return d, nil
+}
+
+func validateAsyncParam(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("async")
+	if v == "" {
+		return false, nil
+	}
+	async, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid async param: %v", err)
+	}
+	return async, nil
+}
+
 func validateSizeParams(r *http.Request) (int, int, error) {
 	w, err := strconv.Atoi(r.URL.Query().Get("weight"))
 	if err != nil {
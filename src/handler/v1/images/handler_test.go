@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"image/color"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
@@ -15,10 +16,16 @@ import (
 	"github.com/disintegration/imaging"
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
+	"github.com/imager/src/config"
+	"github.com/imager/src/imgproc/palette"
+	"github.com/imager/src/imgproc/phash"
+	"github.com/imager/src/imgproc/placeholder"
 	mock_downloader "github.com/imager/src/mock/downloader"
 	mock_model "github.com/imager/src/mock/model"
 	mock_uploader "github.com/imager/src/mock/uploader"
 	"github.com/imager/src/model"
+	"github.com/imager/src/pool"
+	"github.com/imager/src/web/downloader"
 )
 
 const testFilePath = "./testdata/test.jpg"
@@ -29,6 +36,7 @@ func TestAll(t *testing.T) {
 
 	type tc struct {
 		name               string
+		query              string
 		getTest            func() *Service
 		expectedStatusCode int
 	}
@@ -39,7 +47,7 @@ func TestAll(t *testing.T) {
 			getTest: func() *Service {
 				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
 				ctx := context.Background()
-				imagesSvc.EXPECT().All(ctx).Return([]model.OriginalResized{}, nil)
+				imagesSvc.EXPECT().All(ctx, model.ImageFilter{}).Return([]model.OriginalResized{}, nil)
 				return NewService(imagesSvc, nil, nil)
 			},
 			expectedStatusCode: http.StatusOK,
@@ -49,17 +57,36 @@ func TestAll(t *testing.T) {
 			getTest: func() *Service {
 				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
 				ctx := context.Background()
-				imagesSvc.EXPECT().All(ctx).Return(nil, errors.New("error"))
+				imagesSvc.EXPECT().All(ctx, model.ImageFilter{}).Return(nil, errors.New("error"))
 				return NewService(imagesSvc, nil, nil)
 			},
 			expectedStatusCode: http.StatusInternalServerError,
 		},
+		{
+			name:  "http.StatusOK: near color filter",
+			query: "?color=near:%23ff0000",
+			getTest: func() *Service {
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				ctx := context.Background()
+				imagesSvc.EXPECT().All(ctx, model.ImageFilter{NearColor: &color.RGBA{R: 0xff, A: 0xff}}).Return([]model.OriginalResized{}, nil)
+				return NewService(imagesSvc, nil, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:  "http.StatusBadRequest: invalid color filter",
+			query: "?color=red",
+			getTest: func() *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
 	}
 
 	for _, tc := range tcs {
 		t.Run(tc.name, func(t *testing.T) {
 			wr := httptest.NewRecorder()
-			url, err := url.Parse("http://images")
+			url, err := url.Parse("http://images" + tc.query)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -73,6 +100,159 @@ func TestAll(t *testing.T) {
 	}
 }
 
+func TestGetByID(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	type tc struct {
+		name               string
+		getTest            func() (*Service, *http.Request, *httptest.ResponseRecorder)
+		expectedStatusCode int
+	}
+
+	tcs := []tc{
+		{
+			name: "http.StatusBadRequest: invalid id",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("", 0, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return NewService(nil, nil, nil), r, wr
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusInternalServerError",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", 0, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{}, errors.New("error"))
+				return NewService(imagesSvc, nil, nil), r, wr
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "http.StatusNotFound",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", 0, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{}, fmt.Errorf("test: %w", model.ErrNotFound))
+				return NewService(imagesSvc, nil, nil), r, wr
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "http.StatusOK",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", 0, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{ID: 1, DominantColor: "#ff0000"}, nil)
+				return NewService(imagesSvc, nil, nil), r, wr
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			svc, r, wr := tc.getTest()
+			svc.GetByID(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+		})
+	}
+}
+
+func TestSimilar(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	type tc struct {
+		name               string
+		query              string
+		getTest            func(r *http.Request) *Service
+		expectedStatusCode int
+	}
+
+	tcs := []tc{
+		{
+			name:  "http.StatusBadRequest: invalid distance",
+			query: "?distance=65",
+			getTest: func(r *http.Request) *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusNotFound",
+			getTest: func(r *http.Request) *Service {
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{}, fmt.Errorf("test: %w", model.ErrNotFound))
+				return NewService(imagesSvc, nil, nil)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "http.StatusUnprocessableEntity: no perceptual hash",
+			getTest: func(r *http.Request) *Service {
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{ID: 1, OriginalID: 2}, nil)
+				return NewService(imagesSvc, nil, nil)
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:  "http.StatusInternalServerError",
+			query: "?distance=5",
+			getTest: func(r *http.Request) *Service {
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{ID: 1, PerceptualHash: "00ff00ff00ff00ff"}, nil)
+				imagesSvc.EXPECT().Similar(r.Context(), 1, 5).Return(nil, errors.New("error"))
+				return NewService(imagesSvc, nil, nil)
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "http.StatusOK",
+			getTest: func(r *http.Request) *Service {
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{ID: 1, PerceptualHash: "00ff00ff00ff00ff"}, nil)
+				imagesSvc.EXPECT().Similar(r.Context(), 1, defaultSimilarDistance).Return([]model.SimilarImage{}, nil)
+				return NewService(imagesSvc, nil, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			url, err := url.Parse("http://images/1/similar" + tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := mux.SetURLVars(&http.Request{URL: url}, map[string]string{"id": "1"})
+			tc.getTest(r).Similar(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+		})
+	}
+}
+
 func createRecorderAndRequest(id string, w, h int) (*http.Request, *httptest.ResponseRecorder, error) {
 	wr := httptest.NewRecorder()
 	url, err := url.Parse(fmt.Sprintf("http://images?weight=%d&height=%d", w, h))
@@ -103,6 +283,23 @@ func writeMultipartData(r *http.Request, b []byte) (*http.Request, error) {
 	return r, nil
 }
 
+// busyPool returns pool which only worker is busy until test ends and which has no queue.
+func busyPool(t *testing.T) *pool.Pool {
+	p := pool.New(1, 0)
+	started, release := make(chan struct{}), make(chan struct{})
+	go p.Do(context.Background(), func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	t.Cleanup(func() {
+		close(release)
+		p.Close()
+	})
+	return p
+}
+
 func readImage() ([]byte, error) {
 	return ioutil.ReadFile(testFilePath)
 }
@@ -147,6 +344,18 @@ func TestResizeByID(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	resizedDominantColor, resizedPalette, err := imageColors(resized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	savedResized := model.Image{
+		Resolution:    fmt.Sprintf("%dx%d", weight, height),
+		DominantColor: resizedDominantColor,
+		Palette:       resizedPalette,
+		Size:          int64(len(resized)),
+	}
+
 	tcs := []tc{
 		{
 			name: "http.StatusBadRequest: invalid params",
@@ -178,11 +387,24 @@ func TestResizeByID(t *testing.T) {
 					t.Fatal(err)
 				}
 				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
-				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{}, errors.New("error"))
+				imagesSvc.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{}, errors.New("error"))
 				return NewService(imagesSvc, nil, nil), r, wr
 			},
 			expectedStatusCode: http.StatusInternalServerError,
 		},
+		{
+			name: "http.StatusNotFound: GetOne not found",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{}, fmt.Errorf("test: %w", model.ErrNotFound))
+				return NewService(imagesSvc, nil, nil), r, wr
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
 		{
 			name: "http.StatusInternalServerError: Download error",
 			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
@@ -191,27 +413,72 @@ func TestResizeByID(t *testing.T) {
 					t.Fatal(err)
 				}
 				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
-				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{}, nil)
+				imagesSvc.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{}, nil)
 				downloadSvc := mock_downloader.NewMockService(mockCtrl)
-				downloadSvc.EXPECT().Download(r.Context(), "").Return(nil, errors.New("error"))
+				downloadSvc.EXPECT().Download(gomock.Any(), "").Return(nil, errors.New("error"))
 				return NewService(imagesSvc, nil, downloadSvc), r, wr
 			},
 			expectedStatusCode: http.StatusInternalServerError,
 		},
 		{
-			name: "http.StatusInternalServerError: decoding file error",
+			name: "http.StatusUnprocessableEntity: downloaded file is too large",
 			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
 				r, wr, err := createRecorderAndRequest("1", weight, height)
 				if err != nil {
 					t.Fatal(err)
 				}
 				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
-				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{}, nil)
+				imagesSvc.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{}, nil)
 				downloadSvc := mock_downloader.NewMockService(mockCtrl)
-				downloadSvc.EXPECT().Download(r.Context(), "").Return([]byte("test"), nil)
+				downloadSvc.EXPECT().Download(gomock.Any(), "").Return(nil, fmt.Errorf("%w: test", downloader.ErrTooLarge))
 				return NewService(imagesSvc, nil, downloadSvc), r, wr
 			},
-			expectedStatusCode: http.StatusInternalServerError,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "http.StatusUnsupportedMediaType: downloaded content type isn't allowed",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{}, nil)
+				downloadSvc := mock_downloader.NewMockService(mockCtrl)
+				downloadSvc.EXPECT().Download(gomock.Any(), "").Return(nil, fmt.Errorf("%w: 'text/html'", downloader.ErrUnsupportedContentType))
+				return NewService(imagesSvc, nil, downloadSvc), r, wr
+			},
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "http.StatusUnsupportedMediaType: unsupported format",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{}, nil)
+				downloadSvc := mock_downloader.NewMockService(mockCtrl)
+				downloadSvc.EXPECT().Download(gomock.Any(), "").Return([]byte("test"), nil)
+				return NewService(imagesSvc, nil, downloadSvc), r, wr
+			},
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "http.StatusUnprocessableEntity: corrupt image",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{}, nil)
+				downloadSvc := mock_downloader.NewMockService(mockCtrl)
+				downloadSvc.EXPECT().Download(gomock.Any(), "").Return(original[:len(original)/2], nil)
+				return NewService(imagesSvc, nil, downloadSvc), r, wr
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "http.StatusInternalServerError: upload file error",
@@ -221,11 +488,11 @@ func TestResizeByID(t *testing.T) {
 					t.Fatal(err)
 				}
 				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
-				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{}, nil)
+				imagesSvc.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{}, nil)
 				downloadSvc := mock_downloader.NewMockService(mockCtrl)
-				downloadSvc.EXPECT().Download(r.Context(), "").Return(original, nil)
+				downloadSvc.EXPECT().Download(gomock.Any(), "").Return(original, nil)
 				uploadSvc := mock_uploader.NewMockService(mockCtrl)
-				uploadSvc.EXPECT().Upload(r.Context(), name(hash), bytes.NewBuffer(resized)).Return("", errors.New("error"))
+				uploadSvc.EXPECT().Upload(gomock.Any(), name(hash), bytes.NewBuffer(resized)).Return("", errors.New("error"))
 				return NewService(imagesSvc, uploadSvc, downloadSvc), r, wr
 			},
 			expectedStatusCode: http.StatusInternalServerError,
@@ -238,16 +505,34 @@ func TestResizeByID(t *testing.T) {
 					t.Fatal(err)
 				}
 				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
-				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{}, nil)
+				imagesSvc.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{}, nil)
 				downloadSvc := mock_downloader.NewMockService(mockCtrl)
-				downloadSvc.EXPECT().Download(r.Context(), "").Return(original, nil)
+				downloadSvc.EXPECT().Download(gomock.Any(), "").Return(original, nil)
 				uploadSvc := mock_uploader.NewMockService(mockCtrl)
-				uploadSvc.EXPECT().Upload(r.Context(), name(hash), bytes.NewBuffer(resized)).Return("", nil)
-				imagesSvc.EXPECT().Save(r.Context(), model.Image{Resolution: fmt.Sprintf("%dx%d", weight, height)}).Return(0, errors.New("error"))
+				uploadSvc.EXPECT().Upload(gomock.Any(), name(hash), bytes.NewBuffer(resized)).Return("", nil)
+				imagesSvc.EXPECT().Save(gomock.Any(), savedResized).Return(0, errors.New("error"))
 				return NewService(imagesSvc, uploadSvc, downloadSvc), r, wr
 			},
 			expectedStatusCode: http.StatusInternalServerError,
 		},
+		{
+			name: "http.StatusConflict: save file conflict",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{}, nil)
+				downloadSvc := mock_downloader.NewMockService(mockCtrl)
+				downloadSvc.EXPECT().Download(gomock.Any(), "").Return(original, nil)
+				uploadSvc := mock_uploader.NewMockService(mockCtrl)
+				uploadSvc.EXPECT().Upload(gomock.Any(), name(hash), bytes.NewBuffer(resized)).Return("", nil)
+				imagesSvc.EXPECT().Save(gomock.Any(), savedResized).Return(0, fmt.Errorf("test: %w", model.ErrConflict))
+				return NewService(imagesSvc, uploadSvc, downloadSvc), r, wr
+			},
+			expectedStatusCode: http.StatusConflict,
+		},
 		{
 			name: "http.StatusCreated",
 			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
@@ -256,12 +541,12 @@ func TestResizeByID(t *testing.T) {
 					t.Fatal(err)
 				}
 				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
-				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{}, nil)
+				imagesSvc.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{}, nil)
 				downloadSvc := mock_downloader.NewMockService(mockCtrl)
-				downloadSvc.EXPECT().Download(r.Context(), "").Return(original, nil)
+				downloadSvc.EXPECT().Download(gomock.Any(), "").Return(original, nil)
 				uploadSvc := mock_uploader.NewMockService(mockCtrl)
-				uploadSvc.EXPECT().Upload(r.Context(), name(hash), bytes.NewBuffer(resized)).Return("", nil)
-				imagesSvc.EXPECT().Save(r.Context(), model.Image{Resolution: fmt.Sprintf("%dx%d", weight, height)}).Return(1, nil)
+				uploadSvc.EXPECT().Upload(gomock.Any(), name(hash), bytes.NewBuffer(resized)).Return("", nil)
+				imagesSvc.EXPECT().Save(gomock.Any(), savedResized).Return(1, nil)
 				return NewService(imagesSvc, uploadSvc, downloadSvc), r, wr
 			},
 			expectedStatusCode: http.StatusCreated,
@@ -289,6 +574,34 @@ func originalImageResolution(b []byte) (int, int, error) {
 	return g.Dx(), g.Dy(), nil
 }
 
+func originalImagePlaceholder(b []byte) (placeholder.Placeholder, error) {
+	img, err := imaging.Decode(bytes.NewReader(b))
+	if err != nil {
+		return placeholder.Placeholder{}, err
+	}
+	return placeholder.Generate(img)
+}
+
+func imageColors(b []byte) (string, []string, error) {
+	img, err := imaging.Decode(bytes.NewReader(b))
+	if err != nil {
+		return "", nil, err
+	}
+	p, err := palette.Extract(img, paletteSize)
+	if err != nil {
+		return "", nil, err
+	}
+	return palette.Hex(p.Dominant), hexColors(p), nil
+}
+
+func imageHash(b []byte) (string, error) {
+	img, err := imaging.Decode(bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	return hexHash(phash.DHash(img)), nil
+}
+
 func TestResize(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -326,6 +639,44 @@ func TestResize(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	originalPlaceholder, err := originalImagePlaceholder(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	originalDominantColor, originalPalette, err := imageColors(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	originalHash, err := imageHash(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resizedDominantColor, resizedPalette, err := imageColors(resized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	savedOriginal := model.Image{
+		Resolution:     fmt.Sprintf("%dx%d", originalImageW, originalImageH),
+		BlurHash:       originalPlaceholder.BlurHash,
+		LQIP:           originalPlaceholder.LQIP,
+		DominantColor:  originalDominantColor,
+		Palette:        originalPalette,
+		PerceptualHash: originalHash,
+		Size:           int64(len(original)),
+	}
+
+	savedResized := model.Image{
+		OriginalID:    1,
+		Resolution:    fmt.Sprintf("%dx%d", weight, height),
+		DominantColor: resizedDominantColor,
+		Palette:       resizedPalette,
+		Size:          int64(len(resized)),
+	}
+
 	tcs := []tc{
 		{
 			name: "http.StatusBadRequest: invalid params",
@@ -349,6 +700,81 @@ func TestResize(t *testing.T) {
 			},
 			expectedStatusCode: http.StatusBadRequest,
 		},
+		{
+			name: "http.StatusUnsupportedMediaType: unsupported format",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r, err = writeMultipartData(r, []byte("test"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return NewService(nil, nil, nil), r, wr
+			},
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "http.StatusUnprocessableEntity: corrupt image",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r, err = writeMultipartData(r, original[:len(original)/2])
+				if err != nil {
+					t.Fatal(err)
+				}
+				return NewService(nil, nil, nil), r, wr
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "http.StatusUnprocessableEntity: output size exceeds limits",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", config.DefaultLimits.MaxOutputWidth+1, 100)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return NewService(nil, nil, nil), r, wr
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "http.StatusRequestEntityTooLarge: body exceeds limits",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r, err = writeMultipartData(r, original)
+				if err != nil {
+					t.Fatal(err)
+				}
+				limits := config.DefaultLimits
+				limits.MaxBodyBytes = 1024
+				return NewService(nil, nil, nil, WithLimits(limits)), r, wr
+			},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "http.StatusUnprocessableEntity: image has too many pixels",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r, err = writeMultipartData(r, original)
+				if err != nil {
+					t.Fatal(err)
+				}
+				limits := config.DefaultLimits
+				limits.MaxInputPixels = originalImageW*originalImageH - 1
+				return NewService(nil, nil, nil, WithLimits(limits)), r, wr
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
 		{
 			name: "http.StatusInternalServerError: error uploading original file",
 			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
@@ -401,11 +827,86 @@ func TestResize(t *testing.T) {
 				uploadSvc.EXPECT().Upload(r.Context(), name(hashOriginal), bytes.NewBuffer(original)).Return("", nil)
 				uploadSvc.EXPECT().Upload(r.Context(), name(hashResized), bytes.NewBuffer(resized)).Return("", nil)
 				imageSvc := mock_model.NewMockImagesRepository(mockCtrl)
-				imageSvc.EXPECT().Save(r.Context(), model.Image{Resolution: fmt.Sprintf("%dx%d", originalImageW, originalImageH)}).Return(0, errors.New("error"))
+				imageSvc.EXPECT().Save(gomock.Any(), savedOriginal).Return(0, errors.New("error"))
 				return NewService(imageSvc, uploadSvc, nil), r, wr
 			},
 			expectedStatusCode: http.StatusInternalServerError,
 		},
+		{
+			name: "http.StatusTooManyRequests: quota exceeded",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r, err = writeMultipartData(r, original)
+				if err != nil {
+					t.Fatal(err)
+				}
+				quotas := mock_model.NewMockQuotasRepository(mockCtrl)
+				quotas.EXPECT().Quota(r.Context()).Return(model.Quota{MaxImages: 10}, nil)
+				quotas.EXPECT().Usage(r.Context()).Return(model.Usage{Images: 9}, nil)
+				return NewService(nil, nil, nil, WithQuotas(quotas)), r, wr
+			},
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name: "http.StatusTooManyRequests: quota taken by concurrent request before saving",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r, err = writeMultipartData(r, original)
+				if err != nil {
+					t.Fatal(err)
+				}
+				quotas := mock_model.NewMockQuotasRepository(mockCtrl)
+				quotas.EXPECT().Quota(gomock.Any()).Return(model.Quota{MaxImages: 10}, nil)
+				quotas.EXPECT().Usage(gomock.Any()).Return(model.Usage{Images: 8}, nil)
+				quotas.EXPECT().Lock(gomock.Any()).Return(model.Quota{MaxImages: 10}, nil)
+				quotas.EXPECT().Usage(gomock.Any()).Return(model.Usage{Images: 9}, nil)
+				uploadSvc := mock_uploader.NewMockService(mockCtrl)
+				uploadSvc.EXPECT().Upload(gomock.Any(), gomock.Any(), gomock.Any()).Return("", nil).Times(2)
+				return NewService(nil, uploadSvc, nil, WithQuotas(quotas)), r, wr
+			},
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name: "http.StatusServiceUnavailable: processing queue is full",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r, err = writeMultipartData(r, original)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return NewService(nil, nil, nil, WithPool(busyPool(t))), r, wr
+			},
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name: "http.StatusConflict: original image conflict",
+			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
+				r, wr, err := createRecorderAndRequest("1", weight, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r, err = writeMultipartData(r, original)
+				if err != nil {
+					t.Fatal(err)
+				}
+				uploadSvc := mock_uploader.NewMockService(mockCtrl)
+				uploadSvc.EXPECT().Upload(r.Context(), name(hashOriginal), bytes.NewBuffer(original)).Return("", nil)
+				uploadSvc.EXPECT().Upload(r.Context(), name(hashResized), bytes.NewBuffer(resized)).Return("", nil)
+				imageSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imageSvc.EXPECT().Save(gomock.Any(), savedOriginal).Return(0, fmt.Errorf("test: %w", model.ErrConflict))
+				return NewService(imageSvc, uploadSvc, nil), r, wr
+			},
+			expectedStatusCode: http.StatusConflict,
+		},
 		{
 			name: "http.StatusInternalServerError: error saving resized image",
 			getTest: func() (*Service, *http.Request, *httptest.ResponseRecorder) {
@@ -421,8 +922,8 @@ func TestResize(t *testing.T) {
 				uploadSvc.EXPECT().Upload(r.Context(), name(hashOriginal), bytes.NewBuffer(original)).Return("", nil)
 				uploadSvc.EXPECT().Upload(r.Context(), name(hashResized), bytes.NewBuffer(resized)).Return("", nil)
 				imageSvc := mock_model.NewMockImagesRepository(mockCtrl)
-				imageSvc.EXPECT().Save(r.Context(), model.Image{Resolution: fmt.Sprintf("%dx%d", originalImageW, originalImageH)}).Return(1, nil)
-				imageSvc.EXPECT().Save(r.Context(), model.Image{OriginalID: 1, Resolution: fmt.Sprintf("%dx%d", weight, height)}).Return(0, errors.New("error"))
+				imageSvc.EXPECT().Save(gomock.Any(), savedOriginal).Return(1, nil)
+				imageSvc.EXPECT().Save(gomock.Any(), savedResized).Return(0, errors.New("error"))
 				return NewService(imageSvc, uploadSvc, nil), r, wr
 			},
 			expectedStatusCode: http.StatusInternalServerError,
@@ -442,8 +943,8 @@ func TestResize(t *testing.T) {
 				uploadSvc.EXPECT().Upload(r.Context(), name(hashOriginal), bytes.NewBuffer(original)).Return("", nil)
 				uploadSvc.EXPECT().Upload(r.Context(), name(hashResized), bytes.NewBuffer(resized)).Return("", nil)
 				imageSvc := mock_model.NewMockImagesRepository(mockCtrl)
-				imageSvc.EXPECT().Save(r.Context(), model.Image{Resolution: fmt.Sprintf("%dx%d", originalImageW, originalImageH)}).Return(1, nil)
-				imageSvc.EXPECT().Save(r.Context(), model.Image{OriginalID: 1, Resolution: fmt.Sprintf("%dx%d", weight, height)}).Return(2, nil)
+				imageSvc.EXPECT().Save(gomock.Any(), savedOriginal).Return(1, nil)
+				imageSvc.EXPECT().Save(gomock.Any(), savedResized).Return(2, nil)
 				return NewService(imageSvc, uploadSvc, nil), r, wr
 			},
 			expectedStatusCode: http.StatusCreated,
@@ -458,6 +959,9 @@ func TestResize(t *testing.T) {
 			if statusCode != tc.expectedStatusCode {
 				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
 			}
+			if statusCode == http.StatusTooManyRequests && wr.Result().Header.Get("Retry-After") == "" {
+				t.Fatal("expected Retry-After header")
+			}
 		})
 	}
 }
@@ -478,7 +982,7 @@ func TestOnlyResized(t *testing.T) {
 			getTest: func() *Service {
 				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
 				ctx := context.Background()
-				imagesSvc.EXPECT().OnlyResized(ctx).Return([]model.Image{}, nil)
+				imagesSvc.EXPECT().OnlyResized(ctx, model.ImageFilter{}).Return([]model.Image{}, nil)
 				return NewService(imagesSvc, nil, nil)
 			},
 			expectedStatusCode: http.StatusOK,
@@ -488,7 +992,7 @@ func TestOnlyResized(t *testing.T) {
 			getTest: func() *Service {
 				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
 				ctx := context.Background()
-				imagesSvc.EXPECT().OnlyResized(ctx).Return(nil, errors.New("error"))
+				imagesSvc.EXPECT().OnlyResized(ctx, model.ImageFilter{}).Return(nil, errors.New("error"))
 				return NewService(imagesSvc, nil, nil)
 			},
 			expectedStatusCode: http.StatusInternalServerError,
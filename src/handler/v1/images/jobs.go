@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/jobs"
+	"github.com/imager/src/logging"
+	"github.com/imager/src/model"
+)
+
+// Job returns status of asynchronous resize and its result or error.
+func (s *Service) Job(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func() ([]byte, int) {
+		if s.jobs == nil {
+			return errorResponse(w, r, http.StatusNotFound, codeNotFound, "async resizes aren't enabled", nil)
+		}
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidID, "error converting id to int", err)
+		}
+		logging.AddAttrs(r.Context(), "job_id", id)
+		job, err := s.jobs.Get(r.Context(), id)
+		if err != nil {
+			statusCode, code := errorStatus(err)
+			return errorResponse(w, r, statusCode, code, fmt.Sprintf("couldn't get job by id: %d", id), err)
+		}
+		b, err := json.Marshal(job)
+		if err != nil {
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error marshaling job", err)
+		}
+		return b, http.StatusOK
+	}()
+	response(w, data, statusCode)
+}
+
+// enqueueResize queues resize of stored image and returns the job with 202.
+func (s *Service) enqueueResize(w http.ResponseWriter, r *http.Request, params model.ResizeParams) ([]byte, int) {
+	if s.jobs == nil {
+		return errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "async resizes aren't enabled", nil)
+	}
+	ctx := r.Context()
+	// Missing images are reported right away instead of failing the job later.
+	if _, err := s.repo.GetOne(ctx, params.ImageID); err != nil {
+		statusCode, code := errorStatus(err)
+		return errorResponse(w, r, statusCode, code, fmt.Sprintf("couldn't get image by id: %d", params.ImageID), err)
+	}
+	job, err := s.jobs.Enqueue(ctx, params)
+	if err != nil {
+		return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error enqueuing job", err)
+	}
+	logging.AddAttrs(ctx, "job_id", job.ID)
+	b, err := json.Marshal(job)
+	if err != nil {
+		return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error marshaling job", err)
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/jobs/%d", job.ID))
+	return b, http.StatusAccepted
+}
+
+// ProcessJob resizes image of job. Errors caused by the job itself are permanent,
+// details of internal errors are logged and kept out of the job.
+func (s *Service) ProcessJob(ctx context.Context, params model.ResizeParams) (model.OriginalResized, error) {
+	opts := resizeOptions{mode: params.Mode, gravity: params.Gravity}
+	v, err := s.resizes.do(ctx, resizeKey(ctx, params.ImageID, params.Width, params.Height, opts), func(ctx context.Context) (interface{}, error) {
+		return s.resizeByID(ctx, params.ImageID, params.Width, params.Height, opts)
+	})
+	if err != nil {
+		statusCode, code := errorStatus(err)
+		if statusCode < http.StatusInternalServerError {
+			// the same message the client would get from the synchronous request.
+			message := apierror.ClientMessage(fmt.Sprintf("error resizing image %d", params.ImageID), err)
+			return model.OriginalResized{}, jobs.Permanent(fmt.Errorf("%s: %s", code, message))
+		}
+		logging.FromContext(ctx).ErrorContext(ctx, "error resizing image", "image_id", params.ImageID, "error", err)
+		return model.OriginalResized{}, errors.New(code)
+	}
+	return v.(model.OriginalResized), nil
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	mock_model "github.com/imager/src/mock/model"
+	"github.com/imager/src/model"
+)
+
+func TestResizeByIDAsync(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	params := model.ResizeParams{ImageID: 1, Width: 10, Height: 10, Mode: modeResize, Gravity: gravityCenter}
+
+	type tc struct {
+		name               string
+		async              string
+		getTest            func() *Service
+		expectedStatusCode int
+	}
+
+	tcs := []tc{
+		{
+			name:  "http.StatusBadRequest: invalid async param",
+			async: "maybe",
+			getTest: func() *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:  "http.StatusBadRequest: jobs disabled",
+			async: "true",
+			getTest: func() *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:  "http.StatusNotFound: unknown image",
+			async: "true",
+			getTest: func() *Service {
+				repo := mock_model.NewMockImagesRepository(mockCtrl)
+				repo.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{}, model.ErrNotFound)
+				return NewService(repo, nil, nil, WithJobs(mock_model.NewMockJobsRepository(mockCtrl)))
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:  "http.StatusInternalServerError: enqueue error",
+			async: "true",
+			getTest: func() *Service {
+				repo := mock_model.NewMockImagesRepository(mockCtrl)
+				repo.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{ID: 1}, nil)
+				jobs := mock_model.NewMockJobsRepository(mockCtrl)
+				jobs.EXPECT().Enqueue(gomock.Any(), params).Return(model.Job{}, errors.New("error"))
+				return NewService(repo, nil, nil, WithJobs(jobs))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:  "http.StatusAccepted",
+			async: "true",
+			getTest: func() *Service {
+				repo := mock_model.NewMockImagesRepository(mockCtrl)
+				repo.EXPECT().GetOne(gomock.Any(), 1).Return(model.Image{ID: 1}, nil)
+				jobs := mock_model.NewMockJobsRepository(mockCtrl)
+				jobs.EXPECT().Enqueue(gomock.Any(), params).Return(model.Job{ID: 5, Status: model.JobQueued, Params: params}, nil)
+				return NewService(repo, nil, nil, WithJobs(jobs))
+			},
+			expectedStatusCode: http.StatusAccepted,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := url.Parse(fmt.Sprintf("http://images?weight=10&height=10&async=%s", tc.async))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := mux.SetURLVars(&http.Request{URL: u}, map[string]string{"id": "1"})
+			wr := httptest.NewRecorder()
+			tc.getTest().ResizeByID(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+			if statusCode == http.StatusAccepted && wr.Header().Get("Location") != "/api/v1/jobs/5" {
+				t.Fatalf("unexpected location: %s", wr.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestJob(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	type tc struct {
+		name               string
+		getTest            func() *Service
+		expectedStatusCode int
+	}
+
+	tcs := []tc{
+		{
+			name: "http.StatusNotFound: jobs disabled",
+			getTest: func() *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "http.StatusNotFound: unknown job",
+			getTest: func() *Service {
+				jobs := mock_model.NewMockJobsRepository(mockCtrl)
+				jobs.EXPECT().Get(gomock.Any(), 5).Return(model.Job{}, model.ErrNotFound)
+				return NewService(nil, nil, nil, WithJobs(jobs))
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "http.StatusOK",
+			getTest: func() *Service {
+				jobs := mock_model.NewMockJobsRepository(mockCtrl)
+				jobs.EXPECT().Get(gomock.Any(), 5).Return(model.Job{ID: 5, Status: model.JobSucceeded, Result: &model.OriginalResized{}}, nil)
+				return NewService(nil, nil, nil, WithJobs(jobs))
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/jobs/5", nil), map[string]string{"id": "5"})
+			tc.getTest().Job(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+		})
+	}
+}
+
+func TestProcessJob(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	type tc struct {
+		name        string
+		err         error
+		expectedErr string
+	}
+
+	tcs := []tc{
+		{
+			name:        "rejected job gets message of synchronous request",
+			err:         fmt.Errorf("pq: no rows in result set: %w", model.ErrNotFound),
+			expectedErr: "not_found: error resizing image 7: not found",
+		},
+		{
+			name:        "server fault gets only code",
+			err:         errors.New("pq: connection refused"),
+			expectedErr: codeInternal,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := mock_model.NewMockImagesRepository(mockCtrl)
+			repo.EXPECT().GetOne(gomock.Any(), 7).Return(model.Image{}, tc.err)
+
+			_, err := NewService(repo, nil, nil).ProcessJob(context.Background(), model.ResizeParams{ImageID: 7, Width: 10, Height: 10})
+			if err == nil || err.Error() != tc.expectedErr {
+				t.Fatalf("expected error is: %s but got: %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+	"github.com/imager/src/config"
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/metrics"
+)
+
+var (
+	// errTooManyPixels is returned when image dimensions exceed MaxInputPixels.
+	errTooManyPixels = errors.New("image has too many pixels")
+	// errUnsupportedFormat is returned when image is encoded in format which can't be decoded.
+	errUnsupportedFormat = errors.New("unsupported image format")
+	// errCorruptImage is returned when image format is known but its data can't be decoded.
+	errCorruptImage = errors.New("corrupt image")
+)
+
+// decodeImage decodes image after checking its dimensions in the header,
+// so huge images are rejected before memory is allocated for their pixels.
+func decodeImage(b []byte, limits config.Limits) (image.Image, error) {
+	defer metrics.ObserveStage(metrics.StageDecode)()
+	metrics.AddBytes(metrics.BytesIn, len(b))
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, fmt.Errorf("%w: %v", errUnsupportedFormat, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorruptImage, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, apierror.WithDetails(errCorruptImage, "invalid image dimensions %dx%d", cfg.Width, cfg.Height)
+	}
+	if pixels := int64(cfg.Width) * int64(cfg.Height); pixels > int64(limits.MaxInputPixels) {
+		return nil, apierror.WithDetails(errTooManyPixels, "image is %dx%d, at most %d pixels are allowed",
+			cfg.Width, cfg.Height, limits.MaxInputPixels)
+	}
+	img, err := imaging.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorruptImage, err)
+	}
+	return img, nil
+}
+
+// validateOutputSize checks that resized image fits into limits.
+func validateOutputSize(width, height int, limits config.Limits) error {
+	if width > limits.MaxOutputWidth {
+		return fmt.Errorf("width %d exceeds maximum of %d", width, limits.MaxOutputWidth)
+	}
+	if height > limits.MaxOutputHeight {
+		return fmt.Errorf("height %d exceeds maximum of %d", height, limits.MaxOutputHeight)
+	}
+	return nil
+}
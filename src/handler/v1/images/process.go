@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+	"github.com/imager/src/imgproc/palette"
+	"github.com/imager/src/imgproc/phash"
+	"github.com/imager/src/imgproc/placeholder"
+	"github.com/imager/src/metrics"
+)
+
+// processedUpload contains results of processing of uploaded original.
+type processedUpload struct {
+	resolution  string
+	placeholder placeholder.Placeholder
+	colors      palette.Palette
+	hash        uint64
+	resized     processedImage
+}
+
+// processedImage is a resized image encoded to bytes.
+type processedImage struct {
+	resolution string
+	colors     palette.Palette
+	encoded    []byte
+}
+
+// process runs CPU-bound fn on the pool, so number of images decoded at once is bounded.
+func (s *Service) process(ctx context.Context, fn func() error) error {
+	if s.pool == nil {
+		return fn()
+	}
+	return s.pool.Do(ctx, fn)
+}
+
+// processUpload analyses uploaded original and resizes it.
+func (s *Service) processUpload(ctx context.Context, b []byte, width, height int, opts resizeOptions) (processedUpload, error) {
+	var res processedUpload
+	err := s.process(ctx, func() error {
+		img, err := decodeImage(b, s.limits)
+		if err != nil {
+			return fmt.Errorf("error decoding file into image: %w", err)
+		}
+		res.resolution = fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+
+		if res.placeholder, err = placeholder.Generate(img); err != nil {
+			return fmt.Errorf("error generating placeholder: %w", err)
+		}
+		if res.colors, err = palette.Extract(img, paletteSize); err != nil {
+			return fmt.Errorf("error extracting colors: %w", err)
+		}
+		res.hash = phash.DHash(img)
+
+		res.resized, err = resizeAndEncode(img, width, height, opts, imgFormat)
+		return err
+	})
+	return res, err
+}
+
+// processVariant decodes original and resizes it.
+func (s *Service) processVariant(ctx context.Context, b []byte, width, height int, opts resizeOptions) (processedImage, error) {
+	var res processedImage
+	err := s.process(ctx, func() error {
+		img, err := decodeImage(b, s.limits)
+		if err != nil {
+			return fmt.Errorf("error decoding file into image: %w", err)
+		}
+		res, err = resizeAndEncode(img, width, height, opts, imgFormat)
+		return err
+	})
+	return res, err
+}
+
+// resizeAndEncode transforms img and encodes result to format.
+func resizeAndEncode(img image.Image, width, height int, opts resizeOptions, format imaging.Format) (processedImage, error) {
+	img, err := transform(img, width, height, opts)
+	if err != nil {
+		return processedImage{}, fmt.Errorf("couldn't resize image: %w", err)
+	}
+	return encode(img, format)
+}
+
+func encode(img image.Image, format imaging.Format) (processedImage, error) {
+	colors, err := palette.Extract(img, paletteSize)
+	if err != nil {
+		return processedImage{}, fmt.Errorf("error extracting colors: %w", err)
+	}
+	stop := metrics.ObserveStage(metrics.StageEncode)
+	buf := new(bytes.Buffer)
+	err = imaging.Encode(buf, img, format)
+	stop()
+	if err != nil {
+		return processedImage{}, fmt.Errorf("error encoding image to %s: %w", format, err)
+	}
+	metrics.AddBytes(metrics.BytesOut, buf.Len())
+	return processedImage{
+		resolution: fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy()),
+		colors:     colors,
+		encoded:    buf.Bytes(),
+	}, nil
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/model"
+)
+
+// errQuotaExceeded is returned when storing images would exceed quota of the tenant.
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// quotaRetryAfter is sent with Retry-After header when quota is exceeded, usage drops only
+// when images are deleted, so it's much longer than waiting for rate limit.
+const quotaRetryAfter = time.Hour
+
+// UsageResponse describes storage used by tenant and its quota.
+type UsageResponse struct {
+	TenantID string
+	Usage    model.Usage
+	Quota    model.Quota
+}
+
+// Usage returns storage used by tenant of the caller and its quota.
+func (s *Service) Usage(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func() ([]byte, int) {
+		ctx := r.Context()
+		if s.quotas == nil {
+			return errorResponse(w, r, http.StatusNotFound, codeNotFound, "quotas aren't enabled", nil)
+		}
+		quota, err := s.quotas.Quota(ctx)
+		if err != nil {
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error getting quota", err)
+		}
+		usage, err := s.quotas.Usage(ctx)
+		if err != nil {
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error getting usage", err)
+		}
+		b, err := json.Marshal(UsageResponse{TenantID: model.TenantFromContext(ctx), Usage: usage, Quota: quota})
+		if err != nil {
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error marshaling usage", err)
+		}
+		return b, http.StatusOK
+	}()
+	response(w, data, statusCode)
+}
+
+// checkQuota returns errQuotaExceeded when storing images of bytes would exceed quota of tenant of ctx.
+// It's checked before uploading, so files over quota aren't stored, and again by reserveQuota when saving.
+func (s *Service) checkQuota(ctx context.Context, images int, bytes int64) error {
+	if s.quotas == nil {
+		return nil
+	}
+	return s.enforceQuota(ctx, s.quotas.Quota, images, bytes)
+}
+
+// reserveQuota checks quota like checkQuota, but locks it until the transaction of ctx ends,
+// so concurrent requests of the tenant can't all pass the check and together exceed the quota.
+func (s *Service) reserveQuota(ctx context.Context, images int, bytes int64) error {
+	if s.quotas == nil {
+		return nil
+	}
+	return s.enforceQuota(ctx, s.quotas.Lock, images, bytes)
+}
+
+func (s *Service) enforceQuota(ctx context.Context, getQuota func(context.Context) (model.Quota, error), images int, bytes int64) error {
+	quota, err := getQuota(ctx)
+	if err != nil {
+		return err
+	}
+	if quota == (model.Quota{}) {
+		return nil
+	}
+	usage, err := s.quotas.Usage(ctx)
+	if err != nil {
+		return err
+	}
+	if quota.Exceeds(usage, images, bytes) {
+		return apierror.WithDetails(errQuotaExceeded, "tenant '%s' stores %d images of %d bytes, quota is %d images of %d bytes",
+			model.TenantFromContext(ctx), usage.Images, usage.Bytes, quota.MaxImages, quota.MaxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_model "github.com/imager/src/mock/model"
+	"github.com/imager/src/model"
+)
+
+func TestUsage(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	type tc struct {
+		name               string
+		getTest            func() *Service
+		expectedStatusCode int
+	}
+
+	tcs := []tc{
+		{
+			name: "http.StatusNotFound: quotas disabled",
+			getTest: func() *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "http.StatusInternalServerError",
+			getTest: func() *Service {
+				quotas := mock_model.NewMockQuotasRepository(mockCtrl)
+				quotas.EXPECT().Quota(gomock.Any()).Return(model.Quota{}, errors.New("error"))
+				return NewService(nil, nil, nil, WithQuotas(quotas))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "http.StatusOK",
+			getTest: func() *Service {
+				quotas := mock_model.NewMockQuotasRepository(mockCtrl)
+				quotas.EXPECT().Quota(gomock.Any()).Return(model.Quota{MaxImages: 10}, nil)
+				quotas.EXPECT().Usage(gomock.Any()).Return(model.Usage{Images: 2, Bytes: 1024}, nil)
+				return NewService(nil, nil, nil, WithQuotas(quotas))
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/usage", nil)
+			tc.getTest().Usage(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+		})
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	type tc struct {
+		name        string
+		quota       model.Quota
+		usage       model.Usage
+		expectedErr error
+	}
+
+	tcs := []tc{
+		{name: "no quota", quota: model.Quota{}},
+		{name: "within quota", quota: model.Quota{MaxImages: 3, MaxBytes: 2048}, usage: model.Usage{Images: 1, Bytes: 1024}},
+		{name: "images exceeded", quota: model.Quota{MaxImages: 2}, usage: model.Usage{Images: 1}, expectedErr: errQuotaExceeded},
+		{name: "bytes exceeded", quota: model.Quota{MaxBytes: 1500}, usage: model.Usage{Bytes: 1024}, expectedErr: errQuotaExceeded},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			quotas := mock_model.NewMockQuotasRepository(mockCtrl)
+			quotas.EXPECT().Quota(gomock.Any()).Return(tc.quota, nil)
+			quotas.EXPECT().Usage(gomock.Any()).Return(tc.usage, nil).AnyTimes()
+			err := NewService(nil, nil, nil, WithQuotas(quotas)).checkQuota(context.Background(), 2, 512)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected error is: %v but got: %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"fmt"
+	"image"
+	"net/http"
+
+	"github.com/disintegration/imaging"
+	"github.com/imager/src/imgproc/smartcrop"
+	"github.com/imager/src/metrics"
+)
+
+const (
+	// modeResize stretches image to requested size.
+	modeResize = "resize"
+	// modeFill crops image to the aspect ratio of requested size before resizing.
+	modeFill = "fill"
+
+	gravityCenter = "center"
+	// gravitySmart crops the region with the most details.
+	gravitySmart = "smart"
+)
+
+// resizeOptions describes how image is fitted into requested size.
+type resizeOptions struct {
+	mode    string
+	gravity string
+}
+
+func validateResizeOptions(r *http.Request) (resizeOptions, error) {
+	opts := resizeOptions{mode: modeResize, gravity: gravityCenter}
+	if mode := r.URL.Query().Get("mode"); mode != "" {
+		if mode != modeResize && mode != modeFill {
+			return resizeOptions{}, fmt.Errorf("invalid mode param, expected one of: %s, %s", modeResize, modeFill)
+		}
+		opts.mode = mode
+	}
+	if gravity := r.URL.Query().Get("gravity"); gravity != "" {
+		if gravity != gravityCenter && gravity != gravitySmart {
+			return resizeOptions{}, fmt.Errorf("invalid gravity param, expected one of: %s, %s", gravityCenter, gravitySmart)
+		}
+		opts.gravity = gravity
+	}
+	if opts.gravity == gravitySmart && opts.mode != modeFill {
+		return resizeOptions{}, fmt.Errorf("gravity %s is only supported with mode %s", gravitySmart, modeFill)
+	}
+	return opts, nil
+}
+
+// transform fits image into width x height according to options.
+func transform(img image.Image, width, height int, opts resizeOptions) (image.Image, error) {
+	defer metrics.ObserveStage(metrics.StageResize)()
+	var res *image.NRGBA
+	switch {
+	case opts.mode == modeFill && opts.gravity == gravitySmart:
+		rect, err := smartcrop.Crop(img, width, height)
+		if err != nil {
+			return nil, err
+		}
+		res = imaging.Resize(imaging.Crop(img, rect), width, height, imaging.NearestNeighbor)
+	case opts.mode == modeFill:
+		res = imaging.Fill(img, width, height, imaging.Center, imaging.NearestNeighbor)
+	default:
+		res = imaging.Resize(img, width, height, imaging.NearestNeighbor)
+	}
+	if res == nil {
+		return nil, fmt.Errorf("couldn't resize image to %dx%d", width, height)
+	}
+	return res, nil
+}
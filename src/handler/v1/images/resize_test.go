@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestTransform(t *testing.T) {
+	type tc struct {
+		name          string
+		width, height int
+		opts          resizeOptions
+		golden        string
+	}
+
+	tcs := []tc{
+		{
+			name:   "fill with center gravity",
+			width:  100,
+			height: 300,
+			opts:   resizeOptions{mode: modeFill, gravity: gravityCenter},
+			golden: "fill_center_100x300.golden.png",
+		},
+		{
+			name:   "fill with smart gravity, portrait",
+			width:  100,
+			height: 300,
+			opts:   resizeOptions{mode: modeFill, gravity: gravitySmart},
+			golden: "fill_smart_100x300.golden.png",
+		},
+		{
+			name:   "fill with smart gravity, landscape",
+			width:  300,
+			height: 100,
+			opts:   resizeOptions{mode: modeFill, gravity: gravitySmart},
+			golden: "fill_smart_300x100.golden.png",
+		},
+	}
+
+	original, err := imaging.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := transform(original, tc.width, tc.height, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g := img.Bounds(); g.Dx() != tc.width || g.Dy() != tc.height {
+				t.Fatalf("expected size is: %dx%d but got: %dx%d", tc.width, tc.height, g.Dx(), g.Dy())
+			}
+
+			golden := filepath.Join("testdata", tc.golden)
+			if *update {
+				if err := imaging.Save(img, golden); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// pixels are compared since encoded bytes change with versions of png and zlib.
+			expected, err := imaging.Open(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if x, y, ok := samePixels(img, expected, goldenTolerance); !ok {
+				t.Fatalf("result differs from %s at %d,%d, run tests with -update flag if the change is expected", golden, x, y)
+			}
+		})
+	}
+}
+
+// goldenTolerance is a difference of color channels of pixels which is still considered the same.
+const goldenTolerance = 2
+
+// samePixels reports whether images have the same size and colors within tolerance, otherwise it returns the first different pixel.
+func samePixels(a, b image.Image, tolerance int) (int, int, bool) {
+	na, nb := imaging.Clone(a), imaging.Clone(b)
+	if na.Bounds().Size() != nb.Bounds().Size() {
+		return 0, 0, false
+	}
+	for i := range na.Pix {
+		if d := int(na.Pix[i]) - int(nb.Pix[i]); d > tolerance || -d > tolerance {
+			p := i / 4
+			return p % na.Bounds().Dx(), p / na.Bounds().Dx(), false
+		}
+	}
+	return 0, 0, true
+}
+
+func TestValidateResizeOptions(t *testing.T) {
+	type tc struct {
+		name        string
+		query       string
+		expected    resizeOptions
+		expectedErr bool
+	}
+
+	tcs := []tc{
+		{name: "defaults", expected: resizeOptions{mode: modeResize, gravity: gravityCenter}},
+		{name: "fill smart", query: "mode=fill&gravity=smart", expected: resizeOptions{mode: modeFill, gravity: gravitySmart}},
+		{name: "invalid mode", query: "mode=fit", expectedErr: true},
+		{name: "invalid gravity", query: "gravity=north", expectedErr: true},
+		{name: "smart gravity without fill", query: "mode=resize&gravity=smart", expectedErr: true},
+		{name: "smart gravity with default mode", query: "gravity=smart", expectedErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := http.NewRequest("", fmt.Sprintf("http://test?%s", tc.query), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts, err := validateResizeOptions(r)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatal("expected error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts != tc.expected {
+				t.Fatalf("expected options are: %+v but got: %+v", tc.expected, opts)
+			}
+		})
+	}
+}
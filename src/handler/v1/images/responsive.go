@@ -0,0 +1,370 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"math"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/gorilla/mux"
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/imgproc/palette"
+	"github.com/imager/src/logging"
+	"github.com/imager/src/model"
+	"github.com/imager/src/web/downloader"
+)
+
+// maxResponsiveWidths limits number of widths generated by one request.
+const maxResponsiveWidths = 10
+
+// responsivePresets contains commonly used sets of widths.
+var responsivePresets = map[string][]int{
+	"default":   {320, 640, 960, 1280, 1920},
+	"thumbnail": {64, 128, 256},
+}
+
+// responsiveFormats contains formats variants can be generated in and their mime types.
+var responsiveFormats = map[string]struct {
+	format   imaging.Format
+	mimeType string
+}{
+	"png":  {imaging.PNG, "image/png"},
+	"jpeg": {imaging.JPEG, "image/jpeg"},
+}
+
+// ResponsiveRequest describes variants which should be generated.
+type ResponsiveRequest struct {
+	// Widths are used when Preset isn't set.
+	Widths []int
+	Preset string
+	// Formats default to png.
+	Formats []string
+	// Sizes is a value of sizes attribute, defaults to the widest variant.
+	Sizes string
+}
+
+// ResponsiveResponse describes generated variants and markup which uses them.
+type ResponsiveResponse struct {
+	Original model.Image
+	Variants []model.Image
+	// SrcSet contains srcset attribute value per mime type.
+	SrcSet  map[string]string
+	Sizes   string
+	Picture string
+}
+
+// variant is an image of specific width and format.
+type variant struct {
+	image  model.Image
+	width  int
+	format string
+}
+
+// Responsive generates variants of image in several widths and formats, existing variants are reused.
+func (s *Service) Responsive(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func() ([]byte, int) {
+		ctx := r.Context()
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidID, "error converting id to int", err)
+		}
+		logging.AddAttrs(ctx, "image_id", id)
+
+		var req ResponsiveRequest
+		r.Body = http.MaxBytesReader(w, r.Body, apierror.MaxJSONBytes)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			if apierror.IsBodyTooLarge(err) {
+				return errorResponse(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge, fmt.Sprintf("request body is too large, at most %d bytes are allowed", apierror.MaxJSONBytes), nil)
+			}
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidBody, "error decoding request", err)
+		}
+		widths, formats, err := validateResponsiveRequest(req)
+		if err != nil {
+			return errorResponse(w, r, http.StatusBadRequest, codeInvalidParams, "error validating request", err)
+		}
+		for _, width := range widths {
+			if err := validateOutputSize(width, 0, s.limits); err != nil {
+				return errorResponse(w, r, http.StatusUnprocessableEntity, codeInvalidParams, "error validating request", err)
+			}
+		}
+
+		original, err := s.repo.GetOne(ctx, id)
+		if err != nil {
+			statusCode, code := errorStatus(err)
+			return errorResponse(w, r, statusCode, code, fmt.Sprintf("couldn't get image by id: %d", id), err)
+		}
+		if original.OriginalID != 0 {
+			return errorResponse(w, r, http.StatusUnprocessableEntity, codeUnprocessable, fmt.Sprintf("image %d is a variant, responsive images are generated from originals", id), nil)
+		}
+		originalWidth, originalHeight, err := parseResolution(original.Resolution)
+		if err != nil {
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, fmt.Sprintf("error parsing resolution of image %d", id), err)
+		}
+
+		widths = capWidths(widths, originalWidth)
+
+		existing, err := s.repo.Variants(ctx, id)
+		if err != nil {
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, fmt.Sprintf("error getting variants of image %d", id), err)
+		}
+
+		existing = sameAspectRatio(existing, originalWidth, originalHeight)
+
+		variants, err := s.responsiveVariants(ctx, original, existing, widths, formats)
+		if errors.Is(err, downloader.ErrTooLarge) {
+			return errorResponse(w, r, http.StatusUnprocessableEntity, codeImageTooLarge, fmt.Sprintf("image %d is too large to process", id), err)
+		}
+		if err != nil {
+			statusCode, code := errorStatus(err)
+			return errorResponse(w, r, statusCode, code, fmt.Sprintf("error generating variants of image %d", id), err)
+		}
+
+		res := responsiveResponse(original, variants, formats, req.Sizes)
+		b, err := json.Marshal(res)
+		if err != nil {
+			return errorResponse(w, r, http.StatusInternalServerError, codeInternal, "error marshaling result", err)
+		}
+		return b, http.StatusCreated
+	}()
+	response(w, data, statusCode)
+}
+
+// responsiveVariants returns variants for every width and format, missing ones are generated from original.
+func (s *Service) responsiveVariants(ctx context.Context, original model.Image, existing []model.Image, widths []int, formats []string) ([]variant, error) {
+	found := map[string]model.Image{}
+	for _, img := range existing {
+		w, _, err := parseResolution(img.Resolution)
+		if err != nil {
+			continue
+		}
+		found[variantKey(w, strings.TrimPrefix(path.Ext(img.DownloadURL), "."))] = img
+	}
+
+	var (
+		originalImg image.Image
+		res         = make([]variant, 0, len(widths)*len(formats))
+	)
+	for _, format := range formats {
+		for _, width := range widths {
+			if img, ok := found[variantKey(width, format)]; ok {
+				res = append(res, variant{image: img, width: width, format: format})
+				continue
+			}
+
+			if originalImg == nil {
+				b, err := s.downloader.Download(ctx, original.DownloadURL)
+				if err != nil {
+					return nil, fmt.Errorf("couldn't download original: %w", err)
+				}
+				err = s.process(ctx, func() error {
+					originalImg, err = decodeImage(b, s.limits)
+					return err
+				})
+				if err != nil {
+					return nil, fmt.Errorf("error decoding original: %w", err)
+				}
+			}
+
+			var processed processedImage
+			err := s.process(ctx, func() error {
+				var err error
+				processed, err = encode(imaging.Resize(originalImg, width, 0, imaging.Lanczos), responsiveFormats[format].format)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			img, err := s.saveVariant(ctx, original.ID, processed, format)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, variant{image: img, width: width, format: format})
+		}
+	}
+	return res, nil
+}
+
+// saveVariant uploads and saves resized image as a variant of original.
+func (s *Service) saveVariant(ctx context.Context, originalID int, img processedImage, format string) (model.Image, error) {
+	hash, err := calculateMD5(bytes.NewReader(img.encoded))
+	if err != nil {
+		return model.Image{}, err
+	}
+
+	size := int64(len(img.encoded))
+	if err := s.checkQuota(ctx, 1, size); err != nil {
+		return model.Image{}, err
+	}
+
+	downloadURL, err := s.uploader.Upload(ctx, fileName(hash, responsiveFormats[format].format), bytes.NewReader(img.encoded))
+	if err != nil {
+		return model.Image{}, fmt.Errorf("error uploading image: %v", err)
+	}
+
+	res := model.Image{
+		DownloadURL:   downloadURL,
+		Resolution:    img.resolution,
+		OriginalID:    originalID,
+		DominantColor: palette.Hex(img.colors.Dominant),
+		Palette:       hexColors(img.colors),
+		Size:          size,
+	}
+	err = s.store(ctx, func(ctx context.Context) error {
+		if err := s.reserveQuota(ctx, 1, size); err != nil {
+			return err
+		}
+		res.ID, err = s.repo.Save(ctx, res)
+		if err != nil {
+			return err
+		}
+		return s.notify(ctx, model.EventImageResized, res)
+	})
+	if err != nil {
+		return model.Image{}, err
+	}
+	return res, nil
+}
+
+func responsiveResponse(original model.Image, variants []variant, formats []string, sizes string) ResponsiveResponse {
+	res := ResponsiveResponse{
+		Original: original,
+		Variants: make([]model.Image, 0, len(variants)),
+		SrcSet:   map[string]string{},
+	}
+
+	byFormat := map[string][]variant{}
+	maxWidth := 0
+	for _, v := range variants {
+		res.Variants = append(res.Variants, v.image)
+		byFormat[v.format] = append(byFormat[v.format], v)
+		if v.width > maxWidth {
+			maxWidth = v.width
+		}
+	}
+
+	res.Sizes = sizes
+	if res.Sizes == "" {
+		res.Sizes = fmt.Sprintf("(max-width: %dpx) 100vw, %dpx", maxWidth, maxWidth)
+	}
+
+	picture := new(strings.Builder)
+	picture.WriteString("<picture>")
+	for _, format := range formats {
+		srcSet := make([]string, 0, len(byFormat[format]))
+		for _, v := range byFormat[format] {
+			srcSet = append(srcSet, fmt.Sprintf("%s %dw", v.image.DownloadURL, v.width))
+		}
+		mimeType := responsiveFormats[format].mimeType
+		res.SrcSet[mimeType] = strings.Join(srcSet, ", ")
+		fmt.Fprintf(picture, `<source type="%s" srcset="%s" sizes="%s">`,
+			mimeType, html.EscapeString(res.SrcSet[mimeType]), html.EscapeString(res.Sizes))
+	}
+
+	// the widest variant of the first format is a fallback for browsers without picture support.
+	fallback := byFormat[formats[0]][len(byFormat[formats[0]])-1]
+	w, h, _ := parseResolution(fallback.image.Resolution)
+	fmt.Fprintf(picture, `<img src="%s" width="%d" height="%d" alt="">`, html.EscapeString(fallback.image.DownloadURL), w, h)
+	picture.WriteString("</picture>")
+	res.Picture = picture.String()
+
+	return res
+}
+
+func validateResponsiveRequest(req ResponsiveRequest) ([]int, []string, error) {
+	widths := req.Widths
+	if req.Preset != "" {
+		preset, ok := responsivePresets[req.Preset]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown preset '%s'", req.Preset)
+		}
+		widths = preset
+	}
+	if len(widths) == 0 {
+		return nil, nil, fmt.Errorf("widths or preset should be set")
+	}
+	if len(widths) > maxResponsiveWidths {
+		return nil, nil, fmt.Errorf("at most %d widths can be requested", maxResponsiveWidths)
+	}
+	for _, w := range widths {
+		if w <= 0 {
+			return nil, nil, fmt.Errorf("width %d is lower or equal 0", w)
+		}
+	}
+
+	formats := req.Formats
+	if len(formats) == 0 {
+		formats = []string{strings.ToLower(imgFormat.String())}
+	}
+	seen := map[string]bool{}
+	for _, f := range formats {
+		if _, ok := responsiveFormats[f]; !ok {
+			return nil, nil, fmt.Errorf("unsupported format '%s'", f)
+		}
+		if seen[f] {
+			return nil, nil, fmt.Errorf("duplicated format '%s'", f)
+		}
+		seen[f] = true
+	}
+
+	return widths, formats, nil
+}
+
+// capWidths removes duplicates and widths bigger than original, images are never upscaled.
+func capWidths(widths []int, originalWidth int) []int {
+	seen := map[int]bool{}
+	res := make([]int, 0, len(widths))
+	for _, w := range widths {
+		if w > originalWidth || seen[w] {
+			continue
+		}
+		seen[w] = true
+		res = append(res, w)
+	}
+	if len(res) == 0 {
+		res = append(res, originalWidth)
+	}
+	sort.Ints(res)
+	return res
+}
+
+// sameAspectRatio keeps only images which weren't stretched when resized from original of width x height.
+func sameAspectRatio(images []model.Image, width, height int) []model.Image {
+	res := make([]model.Image, 0, len(images))
+	for _, img := range images {
+		w, h, err := parseResolution(img.Resolution)
+		if err != nil {
+			continue
+		}
+		if expected := int(math.Round(float64(w) * float64(height) / float64(width))); h < expected-1 || h > expected+1 {
+			continue
+		}
+		res = append(res, img)
+	}
+	return res
+}
+
+func variantKey(width int, format string) string {
+	return fmt.Sprintf("%d.%s", width, format)
+}
+
+func parseResolution(resolution string) (int, int, error) {
+	var w, h int
+	if _, err := fmt.Sscanf(resolution, "%dx%d", &w, &h); err != nil {
+		return 0, 0, fmt.Errorf("invalid resolution '%s'", resolution)
+	}
+	if w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("invalid resolution '%s'", resolution)
+	}
+	return w, h, nil
+}
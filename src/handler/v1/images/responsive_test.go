@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/imager/src/handler/v1/apierror"
+	mock_downloader "github.com/imager/src/mock/downloader"
+	mock_model "github.com/imager/src/mock/model"
+	mock_uploader "github.com/imager/src/mock/uploader"
+	"github.com/imager/src/model"
+)
+
+func createResponsiveRequest(id, body string) (*http.Request, *httptest.ResponseRecorder, error) {
+	r, err := http.NewRequest("POST", "http://images/"+id+"/responsive", strings.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	return mux.SetURLVars(r, map[string]string{"id": id}), httptest.NewRecorder(), nil
+}
+
+func TestResponsive(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	original, err := readImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	originalW, originalH, err := originalImageResolution(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	originalImage := model.Image{
+		ID:          1,
+		DownloadURL: "http://storage/original.jpeg",
+		Resolution:  fmt.Sprintf("%dx%d", originalW, originalH),
+	}
+
+	existingVariant := model.Image{
+		ID:          2,
+		DownloadURL: "http://storage/existing.png",
+		Resolution:  fmt.Sprintf("320x%d", int(math.Round(320*float64(originalH)/float64(originalW)))),
+		OriginalID:  1,
+	}
+
+	type tc struct {
+		name               string
+		body               string
+		getTest            func(r *http.Request) *Service
+		expectedStatusCode int
+		check              func(t *testing.T, res ResponsiveResponse)
+	}
+
+	tcs := []tc{
+		{
+			name: "http.StatusBadRequest: invalid body",
+			body: "{",
+			getTest: func(r *http.Request) *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusRequestEntityTooLarge",
+			body: `{"Name": "` + strings.Repeat("a", apierror.MaxJSONBytes) + `"}`,
+			getTest: func(r *http.Request) *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "http.StatusBadRequest: unknown preset",
+			body: `{"Preset": "huge"}`,
+			getTest: func(r *http.Request) *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusBadRequest: unsupported format",
+			body: `{"Widths": [320], "Formats": ["bmp"]}`,
+			getTest: func(r *http.Request) *Service {
+				return NewService(nil, nil, nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusNotFound: unknown image",
+			body: `{"Widths": [320]}`,
+			getTest: func(r *http.Request) *Service {
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(model.Image{}, fmt.Errorf("test: %w", model.ErrNotFound))
+				return NewService(imagesSvc, nil, nil)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "http.StatusUnprocessableEntity: variant requested",
+			body: `{"Widths": [320]}`,
+			getTest: func(r *http.Request) *Service {
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(existingVariant, nil)
+				return NewService(imagesSvc, nil, nil)
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "http.StatusCreated: existing variant is reused",
+			body: `{"Widths": [320, 640, 8000], "Formats": ["png", "jpeg"]}`,
+			getTest: func(r *http.Request) *Service {
+				imagesSvc := mock_model.NewMockImagesRepository(mockCtrl)
+				imagesSvc.EXPECT().GetOne(r.Context(), 1).Return(originalImage, nil)
+				imagesSvc.EXPECT().Variants(r.Context(), 1).Return([]model.Image{existingVariant}, nil)
+				downloadSvc := mock_downloader.NewMockService(mockCtrl)
+				downloadSvc.EXPECT().Download(r.Context(), originalImage.DownloadURL).Return(original, nil)
+				uploadSvc := mock_uploader.NewMockService(mockCtrl)
+				for i := 0; i < 3; i++ {
+					url := fmt.Sprintf("http://storage/generated-%d", i)
+					uploadSvc.EXPECT().Upload(r.Context(), gomock.Any(), gomock.Any()).Return(url, nil)
+					imagesSvc.EXPECT().Save(gomock.Any(), gomock.Any()).Return(3+i, nil)
+				}
+				return NewService(imagesSvc, uploadSvc, downloadSvc)
+			},
+			expectedStatusCode: http.StatusCreated,
+			check: func(t *testing.T, res ResponsiveResponse) {
+				if len(res.Variants) != 4 {
+					t.Fatalf("expected 4 variants but got: %d", len(res.Variants))
+				}
+				if !strings.HasPrefix(res.SrcSet["image/png"], "http://storage/existing.png 320w, ") {
+					t.Fatalf("expected existing variant to be reused, got srcset: %s", res.SrcSet["image/png"])
+				}
+				if !strings.Contains(res.SrcSet["image/jpeg"], " 640w") {
+					t.Fatalf("expected jpeg srcset to contain 640w, got: %s", res.SrcSet["image/jpeg"])
+				}
+				if res.Sizes != "(max-width: 640px) 100vw, 640px" {
+					t.Fatalf("unexpected sizes: %s", res.Sizes)
+				}
+				if !strings.HasPrefix(res.Picture, `<picture><source type="image/png"`) || !strings.HasSuffix(res.Picture, `alt=""></picture>`) {
+					t.Fatalf("unexpected picture: %s", res.Picture)
+				}
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			r, wr, err := createResponsiveRequest("1", tc.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.getTest(r).Responsive(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+			if tc.check == nil {
+				return
+			}
+			var res ResponsiveResponse
+			if err := json.NewDecoder(bytes.NewReader(wr.Body.Bytes())).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			tc.check(t, res)
+		})
+	}
+}
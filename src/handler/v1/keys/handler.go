@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/imager/src/auth"
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/model"
+)
+
+const (
+	codeInvalidID     = "invalid_id"
+	codeInvalidBody   = "invalid_body"
+	codeInvalidParams = "invalid_params"
+	codeNotFound      = "not_found"
+)
+
+// Service contains API keys repository.
+type Service struct {
+	repo model.APIKeysRepository
+}
+
+// NewService creates new Service.
+func NewService(repo model.APIKeysRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// IssueRequest describes key which should be issued.
+type IssueRequest struct {
+	Name string
+	// TenantID defaults to tenant of the admin issuing the key and can't be another one,
+	// keys of other tenants are issued with the apikey command.
+	TenantID string
+	Scopes   []string
+}
+
+// IssueResponse contains issued key and its secret, the secret is returned only once.
+type IssueResponse struct {
+	Key    model.APIKey
+	Secret string
+}
+
+// Issue creates new API key.
+func (s *Service) Issue(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func() ([]byte, int) {
+		var req IssueRequest
+		r.Body = http.MaxBytesReader(w, r.Body, apierror.MaxJSONBytes)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			if apierror.IsBodyTooLarge(err) {
+				return apierror.New(w, r, http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge,
+					fmt.Sprintf("request body is too large, at most %d bytes are allowed", apierror.MaxJSONBytes), nil)
+			}
+			return apierror.New(w, r, http.StatusBadRequest, codeInvalidBody, "error decoding request", err)
+		}
+		if err := validateIssueRequest(req); err != nil {
+			return apierror.New(w, r, http.StatusBadRequest, codeInvalidParams, "error validating request", err)
+		}
+
+		secret, err := auth.GenerateKey()
+		if err != nil {
+			return apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, "error generating key", err)
+		}
+		tenantID := model.TenantFromContext(r.Context())
+		if req.TenantID != "" && req.TenantID != tenantID {
+			return apierror.New(w, r, http.StatusForbidden, apierror.CodeForbidden,
+				fmt.Sprintf("keys of tenant '%s' can't issue keys for tenant '%s'", tenantID, req.TenantID), nil)
+		}
+		key, err := s.repo.Create(r.Context(), model.APIKey{Name: req.Name, TenantID: tenantID, Scopes: req.Scopes}, auth.HashKey(secret))
+		if err != nil {
+			return apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, "error saving key", err)
+		}
+
+		b, err := json.Marshal(IssueResponse{Key: key, Secret: secret})
+		if err != nil {
+			return apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, "error marshaling result", err)
+		}
+		return b, http.StatusCreated
+	}()
+	apierror.Write(w, data, statusCode)
+}
+
+// Revoke revokes API key by id.
+func (s *Service) Revoke(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func() ([]byte, int) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			return apierror.New(w, r, http.StatusBadRequest, codeInvalidID, "error converting id to int", err)
+		}
+		err = s.repo.Revoke(r.Context(), id)
+		if errors.Is(err, model.ErrNotFound) {
+			return apierror.New(w, r, http.StatusNotFound, codeNotFound, fmt.Sprintf("key %d doesn't exist or is already revoked", id), nil)
+		}
+		if err != nil {
+			return apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("error revoking key %d", id), err)
+		}
+		return nil, http.StatusNoContent
+	}()
+	apierror.Write(w, data, statusCode)
+}
+
+func validateIssueRequest(req IssueRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("name should be set")
+	}
+	if req.TenantID != "" && !model.ValidTenantID(req.TenantID) {
+		return fmt.Errorf("tenant id '%s' should consist of 1 to 64 lowercase letters, digits and dashes", req.TenantID)
+	}
+	return model.ValidateScopes(req.Scopes)
+}
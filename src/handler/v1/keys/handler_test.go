@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/imager/src/auth"
+	"github.com/imager/src/handler/v1/apierror"
+	mock_model "github.com/imager/src/mock/model"
+	"github.com/imager/src/model"
+)
+
+func TestIssue(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	type tc struct {
+		name               string
+		ctx                context.Context
+		body               string
+		getTest            func() *Service
+		expectedStatusCode int
+	}
+
+	tcs := []tc{
+		{
+			name: "http.StatusBadRequest: invalid body",
+			body: "{",
+			getTest: func() *Service {
+				return NewService(nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusRequestEntityTooLarge",
+			body: `{"Name": "` + strings.Repeat("a", apierror.MaxJSONBytes) + `"}`,
+			getTest: func() *Service {
+				return NewService(nil)
+			},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "http.StatusBadRequest: unknown scope",
+			body: `{"Name": "test", "Scopes": ["images:all"]}`,
+			getTest: func() *Service {
+				return NewService(nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusBadRequest: invalid tenant",
+			body: `{"Name": "test", "TenantID": "../team-b", "Scopes": ["images:read"]}`,
+			getTest: func() *Service {
+				return NewService(nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusForbidden: foreign tenant",
+			ctx:  model.WithTenant(context.Background(), "team-a"),
+			body: `{"Name": "test", "TenantID": "team-b", "Scopes": ["images:read"]}`,
+			getTest: func() *Service {
+				return NewService(nil)
+			},
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name: "http.StatusForbidden: foreign tenant by default tenant admin",
+			body: `{"Name": "test", "TenantID": "team-b", "Scopes": ["images:read"]}`,
+			getTest: func() *Service {
+				return NewService(nil)
+			},
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name: "http.StatusCreated: own tenant",
+			ctx:  model.WithTenant(context.Background(), "team-a"),
+			body: `{"Name": "test", "TenantID": "team-a", "Scopes": ["images:read"]}`,
+			getTest: func() *Service {
+				keysRepo := mock_model.NewMockAPIKeysRepository(mockCtrl)
+				keysRepo.EXPECT().Create(gomock.Any(), model.APIKey{Name: "test", TenantID: "team-a", Scopes: []string{model.ScopeImagesRead}}, gomock.Any()).
+					Return(model.APIKey{ID: 1, Name: "test", TenantID: "team-a", Scopes: []string{model.ScopeImagesRead}}, nil)
+				return NewService(keysRepo)
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name: "http.StatusInternalServerError",
+			body: `{"Name": "test", "Scopes": ["images:read"]}`,
+			getTest: func() *Service {
+				keysRepo := mock_model.NewMockAPIKeysRepository(mockCtrl)
+				keysRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(model.APIKey{}, errors.New("error"))
+				return NewService(keysRepo)
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "http.StatusCreated",
+			body: `{"Name": "test", "Scopes": ["images:read"]}`,
+			getTest: func() *Service {
+				keysRepo := mock_model.NewMockAPIKeysRepository(mockCtrl)
+				keysRepo.EXPECT().Create(gomock.Any(), model.APIKey{Name: "test", TenantID: model.DefaultTenant, Scopes: []string{model.ScopeImagesRead}}, gomock.Any()).
+					Return(model.APIKey{ID: 1, Name: "test", Scopes: []string{model.ScopeImagesRead}}, nil)
+				return NewService(keysRepo)
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/keys", bytes.NewBufferString(tc.body))
+			if tc.ctx != nil {
+				r = r.WithContext(tc.ctx)
+			}
+			tc.getTest().Issue(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+			if statusCode != http.StatusCreated {
+				return
+			}
+			var res IssueResponse
+			if err := json.NewDecoder(wr.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(res.Secret, "imgr_") || auth.HashKey(res.Secret) == res.Secret {
+				t.Fatalf("expected generated secret, got: %s", res.Secret)
+			}
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	type tc struct {
+		name               string
+		getTest            func() *Service
+		expectedStatusCode int
+	}
+
+	tcs := []tc{
+		{
+			name: "http.StatusNotFound",
+			getTest: func() *Service {
+				keysRepo := mock_model.NewMockAPIKeysRepository(mockCtrl)
+				keysRepo.EXPECT().Revoke(gomock.Any(), 1).Return(fmt.Errorf("test: %w", model.ErrNotFound))
+				return NewService(keysRepo)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "http.StatusNoContent",
+			getTest: func() *Service {
+				keysRepo := mock_model.NewMockAPIKeysRepository(mockCtrl)
+				keysRepo.EXPECT().Revoke(gomock.Any(), 1).Return(nil)
+				return NewService(keysRepo)
+			},
+			expectedStatusCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			r := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/v1/keys/1", nil), map[string]string{"id": "1"})
+			tc.getTest().Revoke(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+		})
+	}
+}
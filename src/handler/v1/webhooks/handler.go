@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/imager/src/handler/v1/apierror"
+	"github.com/imager/src/model"
+	"github.com/imager/src/webhooks"
+)
+
+const (
+	codeInvalidID     = "invalid_id"
+	codeInvalidBody   = "invalid_body"
+	codeInvalidParams = "invalid_params"
+	codeNotFound      = "not_found"
+)
+
+// Service contains webhooks repository.
+type Service struct {
+	repo model.WebhooksRepository
+}
+
+// NewService creates new Service.
+func NewService(repo model.WebhooksRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// SubscribeRequest describes url which should receive events.
+type SubscribeRequest struct {
+	URL string
+	// Events default to all event types.
+	Events []string
+}
+
+// Subscribe creates subscription of tenant of the caller, its secret is returned only once.
+func (s *Service) Subscribe(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func() ([]byte, int) {
+		var req SubscribeRequest
+		r.Body = http.MaxBytesReader(w, r.Body, apierror.MaxJSONBytes)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			if apierror.IsBodyTooLarge(err) {
+				return apierror.New(w, r, http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge,
+					fmt.Sprintf("request body is too large, at most %d bytes are allowed", apierror.MaxJSONBytes), nil)
+			}
+			return apierror.New(w, r, http.StatusBadRequest, codeInvalidBody, "error decoding request", err)
+		}
+		if len(req.Events) == 0 {
+			req.Events = model.EventTypes
+		}
+		if err := validateSubscribeRequest(req); err != nil {
+			return apierror.New(w, r, http.StatusBadRequest, codeInvalidParams, "error validating request", err)
+		}
+
+		secret, err := webhooks.GenerateSecret()
+		if err != nil {
+			return apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, "error generating secret", err)
+		}
+		sub, err := s.repo.Subscribe(r.Context(), model.Subscription{URL: req.URL, Events: req.Events, Secret: secret})
+		if err != nil {
+			return apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, "error saving subscription", err)
+		}
+
+		b, err := json.Marshal(sub)
+		if err != nil {
+			return apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, "error marshaling result", err)
+		}
+		return b, http.StatusCreated
+	}()
+	apierror.Write(w, data, statusCode)
+}
+
+// Subscriptions returns subscriptions of tenant of the caller.
+func (s *Service) Subscriptions(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func() ([]byte, int) {
+		subs, err := s.repo.Subscriptions(r.Context())
+		if err != nil {
+			return apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, "error getting subscriptions", err)
+		}
+		b, err := json.Marshal(subs)
+		if err != nil {
+			return apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, "error marshaling subscriptions", err)
+		}
+		return b, http.StatusOK
+	}()
+	apierror.Write(w, data, statusCode)
+}
+
+// Unsubscribe deletes subscription by id.
+func (s *Service) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func() ([]byte, int) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			return apierror.New(w, r, http.StatusBadRequest, codeInvalidID, "error converting id to int", err)
+		}
+		err = s.repo.Unsubscribe(r.Context(), id)
+		if errors.Is(err, model.ErrNotFound) {
+			return apierror.New(w, r, http.StatusNotFound, codeNotFound, fmt.Sprintf("subscription %d doesn't exist", id), nil)
+		}
+		if err != nil {
+			return apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("error deleting subscription %d", id), err)
+		}
+		return nil, http.StatusNoContent
+	}()
+	apierror.Write(w, data, statusCode)
+}
+
+// Deliveries returns the latest deliveries of subscription.
+func (s *Service) Deliveries(w http.ResponseWriter, r *http.Request) {
+	data, statusCode := func() ([]byte, int) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			return apierror.New(w, r, http.StatusBadRequest, codeInvalidID, "error converting id to int", err)
+		}
+		deliveries, err := s.repo.Deliveries(r.Context(), id)
+		if err != nil {
+			return apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("error getting deliveries of subscription %d", id), err)
+		}
+		b, err := json.Marshal(deliveries)
+		if err != nil {
+			return apierror.New(w, r, http.StatusInternalServerError, apierror.CodeInternal, "error marshaling deliveries", err)
+		}
+		return b, http.StatusOK
+	}()
+	apierror.Write(w, data, statusCode)
+}
+
+func validateSubscribeRequest(req SubscribeRequest) error {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url should be absolute http or https url")
+	}
+	for _, event := range req.Events {
+		if !knownEvent(event) {
+			return fmt.Errorf("unknown event '%s', known events are: %s", event, strings.Join(model.EventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+func knownEvent(event string) bool {
+	for _, e := range model.EventTypes {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
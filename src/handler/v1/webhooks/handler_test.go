@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/imager/src/handler/v1/apierror"
+	mock_model "github.com/imager/src/mock/model"
+	"github.com/imager/src/model"
+)
+
+func TestSubscribe(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	type tc struct {
+		name               string
+		body               string
+		getTest            func() *Service
+		expectedStatusCode int
+	}
+
+	tcs := []tc{
+		{
+			name: "http.StatusBadRequest: invalid body",
+			body: "{",
+			getTest: func() *Service {
+				return NewService(nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusRequestEntityTooLarge",
+			body: `{"Name": "` + strings.Repeat("a", apierror.MaxJSONBytes) + `"}`,
+			getTest: func() *Service {
+				return NewService(nil)
+			},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "http.StatusBadRequest: relative url",
+			body: `{"URL": "/hooks"}`,
+			getTest: func() *Service {
+				return NewService(nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusBadRequest: unknown event",
+			body: `{"URL": "https://example.com/hooks", "Events": ["image.updated"]}`,
+			getTest: func() *Service {
+				return NewService(nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "http.StatusInternalServerError",
+			body: `{"URL": "https://example.com/hooks"}`,
+			getTest: func() *Service {
+				repo := mock_model.NewMockWebhooksRepository(mockCtrl)
+				repo.EXPECT().Subscribe(gomock.Any(), gomock.Any()).Return(model.Subscription{}, errors.New("error"))
+				return NewService(repo)
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "http.StatusCreated: all events by default",
+			body: `{"URL": "https://example.com/hooks"}`,
+			getTest: func() *Service {
+				repo := mock_model.NewMockWebhooksRepository(mockCtrl)
+				repo.EXPECT().Subscribe(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, sub model.Subscription) (model.Subscription, error) {
+					if len(sub.Events) != len(model.EventTypes) {
+						t.Fatalf("expected events are: %v but got: %v", model.EventTypes, sub.Events)
+					}
+					if !strings.HasPrefix(sub.Secret, "whsec_") {
+						t.Fatalf("unexpected secret: %s", sub.Secret)
+					}
+					sub.ID = 1
+					return sub, nil
+				})
+				return NewService(repo)
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(tc.body))
+			tc.getTest().Subscribe(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+		})
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	type tc struct {
+		name               string
+		err                error
+		expectedStatusCode int
+	}
+
+	tcs := []tc{
+		{name: "http.StatusNoContent", expectedStatusCode: http.StatusNoContent},
+		{name: "http.StatusNotFound", err: model.ErrNotFound, expectedStatusCode: http.StatusNotFound},
+		{name: "http.StatusInternalServerError", err: errors.New("error"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := mock_model.NewMockWebhooksRepository(mockCtrl)
+			repo.EXPECT().Unsubscribe(gomock.Any(), 1).Return(tc.err)
+
+			wr := httptest.NewRecorder()
+			r := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/v1/webhooks/1", nil), map[string]string{"id": "1"})
+			NewService(repo).Unsubscribe(wr, r)
+			statusCode := wr.Result().StatusCode
+			if statusCode != tc.expectedStatusCode {
+				t.Fatalf("expected status code is: %d but got: %d", tc.expectedStatusCode, statusCode)
+			}
+		})
+	}
+}
+
+func TestDeliveries(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	repo := mock_model.NewMockWebhooksRepository(mockCtrl)
+	repo.EXPECT().Deliveries(gomock.Any(), 1).Return([]model.Delivery{{ID: 2, SubscriptionID: 1, Status: model.DeliveryDelivered}}, nil)
+
+	wr := httptest.NewRecorder()
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/webhooks/1/deliveries", nil), map[string]string{"id": "1"})
+	NewService(repo).Deliveries(wr, r)
+	if statusCode := wr.Result().StatusCode; statusCode != http.StatusOK {
+		t.Fatalf("expected status code is: %d but got: %d", http.StatusOK, statusCode)
+	}
+}
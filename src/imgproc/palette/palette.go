@@ -0,0 +1,161 @@
+package palette
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// sampleSize is a size of the image colors are extracted from.
+	sampleSize = 64
+	// minAlpha is a minimal alpha of the pixel to be counted,
+	// mostly transparent pixels don't contribute to the visible color.
+	minAlpha = 128
+)
+
+// Palette describes the most representative colors of an image.
+type Palette struct {
+	// Dominant is a color covering the biggest part of the image.
+	Dominant color.RGBA
+	// Colors are sorted by covered area, the first one is always Dominant.
+	Colors []color.RGBA
+}
+
+// Extract finds up to n representative colors of image using median cut quantization.
+func Extract(img image.Image, n int) (Palette, error) {
+	if n <= 0 {
+		return Palette{}, fmt.Errorf("number of colors should be greater than 0, got: %d", n)
+	}
+
+	pixels := samplePixels(img)
+	if len(pixels) == 0 {
+		return Palette{}, fmt.Errorf("image has no visible pixels")
+	}
+
+	boxes := []box{pixels}
+	for len(boxes) < n {
+		i := widestBox(boxes)
+		if i < 0 {
+			break
+		}
+		left, right := boxes[i].split()
+		boxes[i] = left
+		boxes = append(boxes, right)
+	}
+
+	sort.SliceStable(boxes, func(i, j int) bool { return len(boxes[i]) > len(boxes[j]) })
+
+	colors := make([]color.RGBA, 0, len(boxes))
+	for _, b := range boxes {
+		colors = append(colors, b.average())
+	}
+
+	return Palette{Dominant: colors[0], Colors: colors}, nil
+}
+
+// Hex formats color as #rrggbb string.
+func Hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// ParseHex parses color in #rrggbb format, leading # is optional.
+func ParseHex(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color '%s', expected format is #rrggbb", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color '%s', expected format is #rrggbb", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+func samplePixels(img image.Image) []color.RGBA {
+	sample := imaging.Fit(img, sampleSize, sampleSize, imaging.Box)
+	bounds := sample.Bounds()
+	pixels := make([]color.RGBA, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := sample.NRGBAAt(x, y)
+			if c.A < minAlpha {
+				continue
+			}
+			pixels = append(pixels, color.RGBA{R: c.R, G: c.G, B: c.B, A: 0xff})
+		}
+	}
+	return pixels
+}
+
+// box is a group of pixels which is split by median of its widest channel.
+type box []color.RGBA
+
+func channel(c color.RGBA, ch int) uint8 {
+	switch ch {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	default:
+		return c.B
+	}
+}
+
+// widestChannel returns channel with the biggest range of values and the range itself.
+func (b box) widestChannel() (int, int) {
+	var widest, widestRange int
+	for ch := 0; ch < 3; ch++ {
+		min, max := uint8(0xff), uint8(0)
+		for _, c := range b {
+			v := channel(c, ch)
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		if r := int(max) - int(min); r > widestRange {
+			widest, widestRange = ch, r
+		}
+	}
+	return widest, widestRange
+}
+
+func (b box) split() (box, box) {
+	ch, _ := b.widestChannel()
+	sort.SliceStable(b, func(i, j int) bool { return channel(b[i], ch) < channel(b[j], ch) })
+	median := len(b) / 2
+	return b[:median], b[median:]
+}
+
+func (b box) average() color.RGBA {
+	var r, g, bl int
+	for _, c := range b {
+		r += int(c.R)
+		g += int(c.G)
+		bl += int(c.B)
+	}
+	n := len(b)
+	return color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: 0xff}
+}
+
+// widestBox returns index of the box which should be split next or -1 if no box can be split.
+func widestBox(boxes []box) int {
+	index, widestRange := -1, 0
+	for i, b := range boxes {
+		if len(b) < 2 {
+			continue
+		}
+		if _, r := b.widestChannel(); r > widestRange {
+			index, widestRange = i, r
+		}
+	}
+	return index
+}
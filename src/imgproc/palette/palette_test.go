@@ -0,0 +1,67 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestExtract(t *testing.T) {
+	red, blue := color.NRGBA{R: 0xff, A: 0xff}, color.NRGBA{B: 0xff, A: 0xff}
+	img := imaging.New(100, 100, red)
+	img = imaging.Paste(img, imaging.New(100, 25, blue), image.Pt(0, 0))
+
+	p, err := Extract(img, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Hex(p.Dominant) != "#ff0000" {
+		t.Fatalf("expected dominant color is: #ff0000 but got: %s", Hex(p.Dominant))
+	}
+	if len(p.Colors) == 0 || p.Colors[0] != p.Dominant {
+		t.Fatalf("expected palette to start with dominant color, got: %v", p.Colors)
+	}
+	var hasBlue bool
+	for _, c := range p.Colors {
+		hasBlue = hasBlue || Hex(c) == "#0000ff"
+	}
+	if !hasBlue {
+		t.Fatalf("expected palette to contain #0000ff, got: %v", p.Colors)
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	type tc struct {
+		name        string
+		value       string
+		expected    color.RGBA
+		expectedErr bool
+	}
+
+	tcs := []tc{
+		{name: "with hash", value: "#ff8000", expected: color.RGBA{R: 0xff, G: 0x80, A: 0xff}},
+		{name: "without hash", value: "00ff00", expected: color.RGBA{G: 0xff, A: 0xff}},
+		{name: "short", value: "#fff", expectedErr: true},
+		{name: "not hex", value: "#gggggg", expectedErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := ParseHex(tc.value)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatal("expected error got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c != tc.expected {
+				t.Fatalf("expected color is: %v but got: %v", tc.expected, c)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package phash
+
+import (
+	"image"
+	"math/bits"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	hashWidth  = 9
+	hashHeight = 8
+)
+
+// DHash calculates 64 bit difference hash of image.
+// Every bit tells whether the pixel is brighter than its right neighbour on 9x8 grayscale thumbnail,
+// so re-encoded, resized or slightly changed copies of the same image get close hashes.
+func DHash(img image.Image) uint64 {
+	thumb := imaging.Grayscale(imaging.Resize(img, hashWidth, hashHeight, imaging.Lanczos))
+
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if thumb.NRGBAAt(x, y).R > thumb.NRGBAAt(x+1, y).R {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance returns Hamming distance between two hashes.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,40 @@
+package phash
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+const testFilePath = "../testdata/test.jpg"
+
+func TestDHash(t *testing.T) {
+	img, err := imaging.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reencoded := new(bytes.Buffer)
+	if err := imaging.Encode(reencoded, imaging.Resize(img, img.Bounds().Dx()/2, 0, imaging.Box), imaging.JPEG, imaging.JPEGQuality(30)); err != nil {
+		t.Fatal(err)
+	}
+	copyImg, err := imaging.Decode(reencoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := DHash(img)
+	if d := Distance(hash, DHash(copyImg)); d > 5 {
+		t.Fatalf("expected re-encoded copy to be within distance 5 but got: %d", d)
+	}
+	if d := Distance(hash, DHash(imaging.FlipH(img))); d < 10 {
+		t.Fatalf("expected flipped image to be further than distance 10 but got: %d", d)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if d := Distance(0xff00, 0x0f00); d != 4 {
+		t.Fatalf("expected distance is: 4 but got: %d", d)
+	}
+}
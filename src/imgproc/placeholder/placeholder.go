@@ -0,0 +1,50 @@
+package placeholder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+
+	"github.com/buckket/go-blurhash"
+	"github.com/disintegration/imaging"
+)
+
+const (
+	blurHashXComponents = 4
+	blurHashYComponents = 3
+	// blurHashSampleSize is a width and height of the image blurhash is computed from,
+	// encoding cost grows with every pixel while the result stays the same. Components don't depend
+	// on aspect ratio, so images are stretched to a square, which also keeps 1px wide images from ringing.
+	blurHashSampleSize = 32
+	lqipWidth          = 16
+	lqipQuality        = 40
+)
+
+// Placeholder describes low-quality representations of an image.
+type Placeholder struct {
+	BlurHash string
+	LQIP     string
+}
+
+// Generate calculates BlurHash string and base64 encoded tiny JPEG data URI for image.
+func Generate(img image.Image) (Placeholder, error) {
+	if img.Bounds().Empty() {
+		return Placeholder{}, fmt.Errorf("can't generate placeholder for empty image")
+	}
+
+	hash, err := blurhash.Encode(blurHashXComponents, blurHashYComponents, imaging.Resize(img, blurHashSampleSize, blurHashSampleSize, imaging.Box))
+	if err != nil {
+		return Placeholder{}, fmt.Errorf("error encoding blurhash: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := imaging.Encode(buf, imaging.Resize(img, lqipWidth, 0, imaging.Box), imaging.JPEG, imaging.JPEGQuality(lqipQuality)); err != nil {
+		return Placeholder{}, fmt.Errorf("error encoding lqip: %v", err)
+	}
+
+	return Placeholder{
+		BlurHash: hash,
+		LQIP:     "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
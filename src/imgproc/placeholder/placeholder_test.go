@@ -0,0 +1,133 @@
+package placeholder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/buckket/go-blurhash"
+	"github.com/disintegration/imaging"
+)
+
+const testFilePath = "../testdata/test.jpg"
+
+func TestGenerate(t *testing.T) {
+	fixture, err := imaging.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := color.NRGBA{R: 0xff, A: 0xff}
+
+	type tc struct {
+		name               string
+		img                image.Image
+		expectedLQIPWidth  int
+		expectedLQIPHeight int
+		expectedErr        bool
+	}
+
+	tcs := []tc{
+		{
+			name:               "fixture",
+			img:                fixture,
+			expectedLQIPWidth:  lqipWidth,
+			expectedLQIPHeight: fixture.Bounds().Dy() * lqipWidth / fixture.Bounds().Dx(),
+		},
+		{
+			name:               "single pixel",
+			img:                imaging.New(1, 1, red),
+			expectedLQIPWidth:  lqipWidth,
+			expectedLQIPHeight: lqipWidth,
+		},
+		{
+			name:               "one pixel high",
+			img:                imaging.New(1000, 1, red),
+			expectedLQIPWidth:  lqipWidth,
+			expectedLQIPHeight: 1,
+		},
+		{
+			name:        "empty",
+			img:         image.NewNRGBA(image.Rect(0, 0, 0, 0)),
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := Generate(tc.img)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			x, y, err := blurhash.Components(p.BlurHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if x != blurHashXComponents || y != blurHashYComponents {
+				t.Fatalf("expected %dx%d blurhash components but got: %dx%d", blurHashXComponents, blurHashYComponents, x, y)
+			}
+			decoded, err := blurhash.Decode(p.BlurHash, blurHashSampleSize, blurHashSampleSize, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := colorDistance(averageColor(decoded), averageColor(tc.img)); d > 16 {
+				t.Fatalf("expected blurhash to keep average color, distance is: %d", d)
+			}
+
+			const prefix = "data:image/jpeg;base64,"
+			if !strings.HasPrefix(p.LQIP, prefix) {
+				t.Fatalf("expected lqip to be jpeg data uri but got: %s", p.LQIP)
+			}
+			b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(p.LQIP, prefix))
+			if err != nil {
+				t.Fatal(err)
+			}
+			lqip, format, err := image.Decode(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != "jpeg" {
+				t.Fatalf("expected lqip format is: jpeg but got: %s", format)
+			}
+			if w, h := lqip.Bounds().Dx(), lqip.Bounds().Dy(); w != tc.expectedLQIPWidth || h < tc.expectedLQIPHeight-1 || h > tc.expectedLQIPHeight+1 {
+				t.Fatalf("expected lqip of %dx%d but got: %dx%d", tc.expectedLQIPWidth, tc.expectedLQIPHeight, w, h)
+			}
+		})
+	}
+}
+
+func averageColor(img image.Image) [3]int {
+	var sum [3]int
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			sum[0] += int(r >> 8)
+			sum[1] += int(g >> 8)
+			sum[2] += int(bl >> 8)
+		}
+	}
+	n := b.Dx() * b.Dy()
+	return [3]int{sum[0] / n, sum[1] / n, sum[2] / n}
+}
+
+func colorDistance(a, b [3]int) int {
+	d := 0
+	for i := range a {
+		if v := a[i] - b[i]; v > d {
+			d = v
+		} else if -v > d {
+			d = -v
+		}
+	}
+	return d
+}
@@ -0,0 +1,136 @@
+package smartcrop
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// analysisSize is a maximal side of the image the crop is searched on,
+// details smaller than a pixel of it don't change the choice much.
+const analysisSize = 256
+
+// Crop finds region of image with the aspect ratio of width x height which contains the most edges.
+// The region is as big as possible, so it's only moved along one axis, ties are resolved towards the center.
+func Crop(img image.Image, width, height int) (image.Rectangle, error) {
+	if width <= 0 || height <= 0 {
+		return image.Rectangle{}, fmt.Errorf("invalid crop size %dx%d", width, height)
+	}
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return image.Rectangle{}, fmt.Errorf("can't crop empty image")
+	}
+
+	cropW, cropH := fitAspect(bounds.Dx(), bounds.Dy(), width, height)
+	if cropW == bounds.Dx() && cropH == bounds.Dy() {
+		return bounds, nil
+	}
+
+	sample := imaging.Fit(img, analysisSize, analysisSize, imaging.Box)
+	scale := float64(bounds.Dx()) / float64(sample.Bounds().Dx())
+	energy := edges(imaging.Grayscale(sample))
+
+	sampleW := int(math.Max(1, math.Round(float64(cropW)/scale)))
+	sampleH := int(math.Max(1, math.Round(float64(cropH)/scale)))
+
+	if cropW < bounds.Dx() {
+		x := int(math.Round(float64(bestOffset(columnSums(energy), sampleW)) * scale))
+		if x+cropW > bounds.Dx() {
+			x = bounds.Dx() - cropW
+		}
+		return image.Rect(x, 0, x+cropW, cropH).Add(bounds.Min), nil
+	}
+	y := int(math.Round(float64(bestOffset(rowSums(energy), sampleH)) * scale))
+	if y+cropH > bounds.Dy() {
+		y = bounds.Dy() - cropH
+	}
+	return image.Rect(0, y, cropW, y+cropH).Add(bounds.Min), nil
+}
+
+// fitAspect returns the biggest size with aspect ratio of width x height which fits into w x h.
+// Sides are at least 1px, so extreme aspect ratios don't give empty crops.
+func fitAspect(w, h, width, height int) (int, int) {
+	if w*height > h*width {
+		return int(math.Max(1, math.Round(float64(h)*float64(width)/float64(height)))), h
+	}
+	return w, int(math.Max(1, math.Round(float64(w)*float64(height)/float64(width))))
+}
+
+// edges returns Sobel gradient magnitude of every pixel of grayscale image.
+func edges(gray *image.NRGBA) [][]float64 {
+	w, h := gray.Bounds().Dx(), gray.Bounds().Dy()
+	at := func(x, y int) float64 {
+		if x < 0 {
+			x = 0
+		}
+		if x >= w {
+			x = w - 1
+		}
+		if y < 0 {
+			y = 0
+		}
+		if y >= h {
+			y = h - 1
+		}
+		return float64(gray.Pix[y*gray.Stride+x*4])
+	}
+
+	res := make([][]float64, h)
+	for y := 0; y < h; y++ {
+		res[y] = make([]float64, w)
+		for x := 0; x < w; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			res[y][x] = math.Sqrt(gx*gx + gy*gy)
+		}
+	}
+	return res
+}
+
+func columnSums(energy [][]float64) []float64 {
+	if len(energy) == 0 {
+		return nil
+	}
+	res := make([]float64, len(energy[0]))
+	for _, row := range energy {
+		for x, v := range row {
+			res[x] += v
+		}
+	}
+	return res
+}
+
+func rowSums(energy [][]float64) []float64 {
+	res := make([]float64, len(energy))
+	for y, row := range energy {
+		for _, v := range row {
+			res[y] += v
+		}
+	}
+	return res
+}
+
+// bestOffset returns start of the window of size which has the biggest sum of sums.
+func bestOffset(sums []float64, size int) int {
+	if size >= len(sums) {
+		return 0
+	}
+
+	var window float64
+	for _, v := range sums[:size] {
+		window += v
+	}
+
+	center := float64(len(sums)-size) / 2
+	best, bestWindow := 0, window
+	for offset := 1; offset+size <= len(sums); offset++ {
+		window += sums[offset+size-1] - sums[offset-1]
+		closer := math.Abs(float64(offset)-center) < math.Abs(float64(best)-center)
+		if window > bestWindow || (window == bestWindow && closer) {
+			best, bestWindow = offset, window
+		}
+	}
+	return best
+}
@@ -0,0 +1,57 @@
+package smartcrop
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestCrop(t *testing.T) {
+	// flat gray image with a checkerboard in the right part.
+	img := imaging.New(400, 100, color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff})
+	for y := 0; y < 100; y++ {
+		for x := 300; x < 400; x++ {
+			if (x/10+y/10)%2 == 0 {
+				img.SetNRGBA(x, y, color.NRGBA{A: 0xff})
+			}
+		}
+	}
+
+	type tc struct {
+		name          string
+		img           image.Image
+		width, height int
+		expected      image.Rectangle
+		// tolerance is a number of pixels crop may be shifted by.
+		tolerance int
+	}
+
+	tcs := []tc{
+		{name: "detailed region", img: img, width: 100, height: 100, expected: image.Rect(300, 0, 400, 100), tolerance: 5},
+		{name: "flat image is cropped at center", img: imaging.New(400, 100, color.White), width: 100, height: 100, expected: image.Rect(150, 0, 250, 100)},
+		{name: "same aspect ratio", img: img, width: 40, height: 10, expected: image.Rect(0, 0, 400, 100)},
+		{name: "extreme aspect ratio keeps 1px", img: imaging.New(1000, 1, color.White), width: 1, height: 1000, expected: image.Rect(500, 0, 501, 1), tolerance: 5},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			rect, err := Crop(tc.img, tc.width, tc.height)
+			if err != nil {
+				t.Fatal(err)
+			}
+			shift := rect.Min.Sub(tc.expected.Min)
+			if rect.Size() != tc.expected.Size() || abs(shift.X) > tc.tolerance || abs(shift.Y) > tc.tolerance {
+				t.Fatalf("expected crop is: %v but got: %v", tc.expected, rect)
+			}
+		})
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,139 @@
+// Package jobs runs queued resize jobs in background.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/imager/src/model"
+)
+
+const (
+	// DefaultMaxAttempts is a number of times job is started before it's failed for good.
+	DefaultMaxAttempts = 5
+	// DefaultPollInterval is a time workers wait for new jobs when the queue is empty.
+	DefaultPollInterval = time.Second
+
+	minBackoff = 2 * time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// Processor resizes images of jobs.
+type Processor interface {
+	ProcessJob(context.Context, model.ResizeParams) (model.OriginalResized, error)
+}
+
+// permanentError marks failures retrying of which won't help, e.g. missing image.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// Worker claims jobs from the queue and processes them.
+type Worker struct {
+	repo         model.JobsRepository
+	processor    Processor
+	maxAttempts  int
+	pollInterval time.Duration
+	now          func() time.Time
+}
+
+// NewWorker returns new worker.
+func NewWorker(repo model.JobsRepository, processor Processor) *Worker {
+	return &Worker{
+		repo:         repo,
+		processor:    processor,
+		maxAttempts:  DefaultMaxAttempts,
+		pollInterval: DefaultPollInterval,
+		now:          time.Now,
+	}
+}
+
+// Run processes jobs on n goroutines until ctx is done. Jobs already started are finished before Run returns.
+func (w *Worker) Run(ctx context.Context, n int) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	for {
+		if w.runOnce(ctx) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.pollInterval):
+		}
+	}
+}
+
+// runOnce processes one job and reports whether there was any.
+func (w *Worker) runOnce(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	job, err := w.repo.Claim(ctx, w.maxAttempts)
+	if errors.Is(err, model.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		slog.Error("error claiming job", "error", err)
+		return false
+	}
+
+	// The job isn't interrupted when ctx is done, so it's not left running until the lease expires.
+	jobCtx := model.WithTenant(context.Background(), job.TenantID)
+	logger := slog.With("job_id", job.ID, "image_id", job.Params.ImageID)
+	res, err := w.processor.ProcessJob(jobCtx, job.Params)
+	if err == nil {
+		if err := w.repo.Complete(jobCtx, job.ID, res); err != nil {
+			logger.Error("error completing job", "error", err)
+		}
+		return true
+	}
+
+	var retryAt time.Time
+	var permanent permanentError
+	if job.Attempts < w.maxAttempts && !errors.As(err, &permanent) {
+		retryAt = w.now().Add(backoff(job.Attempts))
+	}
+	if err := w.repo.Fail(jobCtx, job.ID, err.Error(), retryAt); err != nil {
+		logger.Error("error failing job", "error", err)
+	}
+	return true
+}
+
+// backoff returns delay before next start of job which was started attempts times.
+func backoff(attempts int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
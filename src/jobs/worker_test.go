@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mock_model "github.com/imager/src/mock/model"
+	"github.com/imager/src/model"
+)
+
+type processorFunc func(context.Context, model.ResizeParams) (model.OriginalResized, error)
+
+func (f processorFunc) ProcessJob(ctx context.Context, params model.ResizeParams) (model.OriginalResized, error) {
+	return f(ctx, params)
+}
+
+func TestRunOnce(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	now := time.Date(2020, 10, 24, 10, 0, 0, 0, time.UTC)
+	job := model.Job{ID: 7, TenantID: "team-a", Params: model.ResizeParams{ImageID: 1, Width: 10, Height: 10}}
+	result := model.OriginalResized{Original: model.Image{ID: 1}, Resized: model.Image{ID: 2, OriginalID: 1}}
+
+	type tc struct {
+		name        string
+		attempts    int
+		processErr  error
+		getRepo     func(*mock_model.MockJobsRepository)
+		expectedRan bool
+	}
+
+	tcs := []tc{
+		{
+			name: "empty queue",
+			getRepo: func(repo *mock_model.MockJobsRepository) {
+				repo.EXPECT().Claim(gomock.Any(), DefaultMaxAttempts).Return(model.Job{}, model.ErrNotFound)
+			},
+		},
+		{
+			name: "claim error",
+			getRepo: func(repo *mock_model.MockJobsRepository) {
+				repo.EXPECT().Claim(gomock.Any(), DefaultMaxAttempts).Return(model.Job{}, errors.New("error"))
+			},
+		},
+		{
+			name:     "succeeded",
+			attempts: 1,
+			getRepo: func(repo *mock_model.MockJobsRepository) {
+				repo.EXPECT().Complete(gomock.Any(), job.ID, result).Return(nil)
+			},
+			expectedRan: true,
+		},
+		{
+			name:       "retried with backoff",
+			attempts:   3,
+			processErr: errors.New("internal_error"),
+			getRepo: func(repo *mock_model.MockJobsRepository) {
+				repo.EXPECT().Fail(gomock.Any(), job.ID, "internal_error", now.Add(8*time.Second)).Return(nil)
+			},
+			expectedRan: true,
+		},
+		{
+			name:       "failed after max attempts",
+			attempts:   DefaultMaxAttempts,
+			processErr: errors.New("internal_error"),
+			getRepo: func(repo *mock_model.MockJobsRepository) {
+				repo.EXPECT().Fail(gomock.Any(), job.ID, "internal_error", time.Time{}).Return(nil)
+			},
+			expectedRan: true,
+		},
+		{
+			name:       "permanent error isn't retried",
+			attempts:   1,
+			processErr: Permanent(errors.New("not_found")),
+			getRepo: func(repo *mock_model.MockJobsRepository) {
+				repo.EXPECT().Fail(gomock.Any(), job.ID, "not_found", time.Time{}).Return(nil)
+			},
+			expectedRan: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := mock_model.NewMockJobsRepository(mockCtrl)
+			if tc.attempts > 0 {
+				claimed := job
+				claimed.Attempts = tc.attempts
+				repo.EXPECT().Claim(gomock.Any(), DefaultMaxAttempts).Return(claimed, nil)
+			}
+			tc.getRepo(repo)
+
+			w := NewWorker(repo, processorFunc(func(ctx context.Context, params model.ResizeParams) (model.OriginalResized, error) {
+				if tenant := model.TenantFromContext(ctx); tenant != job.TenantID {
+					t.Fatalf("expected tenant is: %s but got: %s", job.TenantID, tenant)
+				}
+				if tc.processErr != nil {
+					return model.OriginalResized{}, tc.processErr
+				}
+				return result, nil
+			}))
+			w.now = func() time.Time { return now }
+
+			if ran := w.runOnce(context.Background()); ran != tc.expectedRan {
+				t.Fatalf("expected ran is: %v but got: %v", tc.expectedRan, ran)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tcs := map[int]time.Duration{
+		1:  2 * time.Second,
+		2:  4 * time.Second,
+		5:  32 * time.Second,
+		20: maxBackoff,
+	}
+	for attempts, expected := range tcs {
+		if d := backoff(attempts); d != expected {
+			t.Fatalf("expected backoff after %d attempts is: %v but got: %v", attempts, expected, d)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/imager/src/web/recorder"
+)
+
+// RequestIDHeader contains id of the request, it's sent back with every response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limits ids sent by clients, so they can't flood logs.
+const maxRequestIDLength = 128
+
+// Middleware takes id of the request from the header or generates new one, sends it back
+// and puts it to ctx of the request. Every request is logged once it's handled.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := WithRequestID(r.Context(), id)
+
+		rec := recorder.New(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		FromContext(ctx).InfoContext(ctx, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", rec.Route,
+			"status", rec.Status,
+			"bytes", rec.Bytes,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote_addr", r.RemoteAddr,
+			"user_agent", r.UserAgent(),
+		)
+	})
+}
+
+// validRequestID accepts ids of reasonable length made of characters safe to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,78 @@
+// Package logging configures structured logs and carries request scoped attributes through contexts.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Formats of logs.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// New returns logger writing records of level and above to w in format.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level '%s': %w", level, err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format '%s', expected one of: %s, %s", format, FormatJSON, FormatText)
+}
+
+type requestKey struct{}
+
+// request holds attributes shared by all records logged while handling a request,
+// handlers add them as they learn e.g. ids of images.
+type request struct {
+	id string
+
+	mu    sync.Mutex
+	attrs []any
+}
+
+// WithRequestID returns ctx carrying id of the request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{id: id})
+}
+
+// RequestID returns id of the request of ctx or empty string outside of requests.
+func RequestID(ctx context.Context) string {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		return req.id
+	}
+	return ""
+}
+
+// AddAttrs adds key-value pairs to every record later logged with ctx of the request, including its access log.
+func AddAttrs(ctx context.Context, args ...any) {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		req.mu.Lock()
+		req.attrs = append(req.attrs, args...)
+		req.mu.Unlock()
+	}
+}
+
+// FromContext returns default logger with id and attributes of the request of ctx.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	req, ok := ctx.Value(requestKey{}).(*request)
+	if !ok {
+		return logger
+	}
+	req.mu.Lock()
+	defer req.mu.Unlock()
+	return logger.With(append([]any{"request_id", req.id}, req.attrs...)...)
+}
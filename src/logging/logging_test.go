@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	type tc struct {
+		name       string
+		requestID  string
+		expectedID string
+	}
+
+	tcs := []tc{
+		{
+			name:       "id of the client is propagated",
+			requestID:  "abc-123",
+			expectedID: "abc-123",
+		},
+		{
+			name: "id is generated",
+		},
+		{
+			name:      "unsafe id is replaced",
+			requestID: "abc\ninjected",
+		},
+		{
+			name:      "too long id is replaced",
+			requestID: strings.Repeat("a", maxRequestIDLength+1),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			defer slog.SetDefault(slog.Default())
+			slog.SetDefault(slog.New(slog.NewJSONHandler(buf, nil)))
+
+			var ctxID string
+			h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID = RequestID(r.Context())
+				AddAttrs(r.Context(), "image_id", 7)
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			wr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/images/7", nil)
+			if tc.requestID != "" {
+				r.Header.Set(RequestIDHeader, tc.requestID)
+			}
+			h.ServeHTTP(wr, r)
+
+			id := wr.Result().Header.Get(RequestIDHeader)
+			if id == "" || id != ctxID {
+				t.Fatalf("expected request id %q of ctx to be sent in header, got: %q", ctxID, id)
+			}
+			if tc.expectedID != "" && id != tc.expectedID {
+				t.Fatalf("expected request id is: %s but got: %s", tc.expectedID, id)
+			}
+			if tc.expectedID == "" && id == tc.requestID {
+				t.Fatalf("expected request id %q to be replaced", tc.requestID)
+			}
+
+			var record struct {
+				RequestID string `json:"request_id"`
+				ImageID   int    `json:"image_id"`
+				Status    int    `json:"status"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatal(err)
+			}
+			if record.RequestID != id || record.ImageID != 7 || record.Status != http.StatusNotFound {
+				t.Fatalf("expected access log of request %s with image 7 and status 404, got: %s", id, buf.String())
+			}
+		})
+	}
+}
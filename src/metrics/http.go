@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/imager/src/web/recorder"
+)
+
+// Middleware counts requests and observes their latency, routes are labeled by path template
+// recorded by recorder.Route, so ids in paths don't multiply series. Unmatched requests are labeled unknown.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := recorder.New(w)
+		next.ServeHTTP(rec, r)
+
+		status := strconv.Itoa(rec.Status)
+		route := rec.Route
+		if route == "" {
+			route = "unknown"
+		}
+		requests.WithLabelValues(route, r.Method, status).Inc()
+		requestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
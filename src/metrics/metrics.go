@@ -0,0 +1,113 @@
+// Package metrics exposes Prometheus metrics of requests, image processing, storage and db.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/imager/src/pool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "imager"
+
+// Stages of image processing.
+const (
+	StageDecode = "decode"
+	StageResize = "resize"
+	StageEncode = "encode"
+)
+
+// Directions of image bytes.
+const (
+	BytesIn  = "in"
+	BytesOut = "out"
+)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time of handling requests by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	processingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "image_processing_duration_seconds",
+		Help:      "Time of decoding, resizing and encoding of images.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"stage"})
+	imageBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_bytes_total",
+		Help:      "Size of decoded (in) and encoded (out) images.",
+	}, []string{"direction"})
+
+	storageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_duration_seconds",
+		Help:      "Time of uploads and downloads by backend.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"backend", "operation"})
+	storageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_errors_total",
+		Help:      "Number of failed uploads and downloads by backend.",
+	}, []string{"backend", "operation"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Time of repository methods.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"repository", "method"})
+)
+
+// Handler serves metrics in Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveStage starts timing of processing stage, returned func records it.
+func ObserveStage(stage string) func() {
+	return since(processingDuration.WithLabelValues(stage))
+}
+
+// AddBytes counts size of processed images.
+func AddBytes(direction string, n int) {
+	imageBytes.WithLabelValues(direction).Add(float64(n))
+}
+
+// ObserveQuery starts timing of repository method, returned func records it.
+func ObserveQuery(repository, method string) func() {
+	return since(queryDuration.WithLabelValues(repository, method))
+}
+
+// RegisterPool exposes number of queued and running tasks of the processing pool.
+func RegisterPool(p *pool.Pool) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pool_queue_depth",
+		Help:      "Number of images waiting for a processing worker.",
+	}, func() float64 { return float64(p.QueueDepth()) })
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pool_in_flight",
+		Help:      "Number of images being processed or waiting for a worker.",
+	}, func() float64 { return float64(p.InFlight()) })
+}
+
+func since(o prometheus.Observer) func() {
+	start := time.Now()
+	return func() {
+		o.Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/imager/src/web/recorder"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(recorder.Route)
+	handler := Middleware(router)
+	router.HandleFunc("/images/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Fatal("expected response writer to support flushing")
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	counter := requests.WithLabelValues("/images/{id}", http.MethodGet, "404")
+	before := testutil.ToFloat64(counter)
+	for _, id := range []string{"1", "2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/images/"+id, nil))
+	}
+	if got := testutil.ToFloat64(counter) - before; got != 2 {
+		t.Fatalf("expected 2 requests counted for route template but got: %v", got)
+	}
+
+	unknown := requests.WithLabelValues("unknown", http.MethodGet, "404")
+	before = testutil.ToFloat64(unknown)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if got := testutil.ToFloat64(unknown) - before; got != 1 {
+		t.Fatalf("expected unmatched request to be counted but got: %v", got)
+	}
+}
+
+type uploaderFunc func() error
+
+func (f uploaderFunc) Upload(context.Context, string, io.Reader) (string, error) {
+	return "", f()
+}
+
+func TestUploader(t *testing.T) {
+	type tc struct {
+		name           string
+		err            error
+		expectedErrors float64
+	}
+
+	tcs := []tc{
+		{name: "success", expectedErrors: 0},
+		{name: "failure", err: errors.New("error"), expectedErrors: 1},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			counter := storageErrors.WithLabelValues("test", OperationUpload)
+			before := testutil.ToFloat64(counter)
+			_, err := Uploader(uploaderFunc(func() error { return tc.err }), "test").Upload(context.Background(), "a.png", nil)
+			if err != tc.err {
+				t.Fatalf("expected error: %v but got: %v", tc.err, err)
+			}
+			if got := testutil.ToFloat64(counter) - before; got != tc.expectedErrors {
+				t.Fatalf("expected %v errors counted but got: %v", tc.expectedErrors, got)
+			}
+		})
+	}
+}
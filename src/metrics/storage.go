@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/imager/src/web/downloader"
+	"github.com/imager/src/web/uploader"
+)
+
+// Operations of storage backends.
+const (
+	OperationUpload   = "upload"
+	OperationDownload = "download"
+)
+
+type instrumentedUploader struct {
+	uploader.Service
+	backend string
+}
+
+// Uploader observes latency and errors of uploads to backend.
+func Uploader(svc uploader.Service, backend string) uploader.Service {
+	return &instrumentedUploader{svc, backend}
+}
+
+func (u *instrumentedUploader) Upload(ctx context.Context, fileName string, r io.Reader) (string, error) {
+	start := time.Now()
+	location, err := u.Service.Upload(ctx, fileName, r)
+	observeStorage(u.backend, OperationUpload, start, err)
+	return location, err
+}
+
+type instrumentedDownloader struct {
+	downloader.Service
+	backend string
+}
+
+// Downloader observes latency and errors of downloads from backend, latency of Open
+// covers time until the body starts.
+func Downloader(svc downloader.Service, backend string) downloader.Service {
+	return &instrumentedDownloader{svc, backend}
+}
+
+func (d *instrumentedDownloader) Download(ctx context.Context, url string) ([]byte, error) {
+	start := time.Now()
+	b, err := d.Service.Download(ctx, url)
+	observeStorage(d.backend, OperationDownload, start, err)
+	return b, err
+}
+
+func (d *instrumentedDownloader) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	start := time.Now()
+	body, err := d.Service.Open(ctx, url)
+	observeStorage(d.backend, OperationDownload, start, err)
+	return body, err
+}
+
+func observeStorage(backend, operation string, start time.Time, err error) {
+	storageDuration.WithLabelValues(backend, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		storageErrors.WithLabelValues(backend, operation).Inc()
+	}
+}
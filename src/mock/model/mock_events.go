@@ -0,0 +1,97 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: model\events.go
+
+// Package mock_model is a generated GoMock package.
+package mock_model
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	model "github.com/imager/src/model"
+)
+
+// MockEventsRepository is a mock of EventsRepository interface.
+type MockEventsRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockEventsRepositoryMockRecorder
+}
+
+// MockEventsRepositoryMockRecorder is the mock recorder for MockEventsRepository.
+type MockEventsRepositoryMockRecorder struct {
+	mock *MockEventsRepository
+}
+
+// NewMockEventsRepository creates a new mock instance.
+func NewMockEventsRepository(ctrl *gomock.Controller) *MockEventsRepository {
+	mock := &MockEventsRepository{ctrl: ctrl}
+	mock.recorder = &MockEventsRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEventsRepository) EXPECT() *MockEventsRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Append mocks base method.
+func (m *MockEventsRepository) Append(arg0 context.Context, arg1 model.Event) (model.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Append", arg0, arg1)
+	ret0, _ := ret[0].(model.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Append indicates an expected call of Append.
+func (mr *MockEventsRepositoryMockRecorder) Append(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Append", reflect.TypeOf((*MockEventsRepository)(nil).Append), arg0, arg1)
+}
+
+// After mocks base method.
+func (m *MockEventsRepository) After(ctx context.Context, afterID, limit int) ([]model.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "After", ctx, afterID, limit)
+	ret0, _ := ret[0].([]model.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// After indicates an expected call of After.
+func (mr *MockEventsRepositoryMockRecorder) After(ctx, afterID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "After", reflect.TypeOf((*MockEventsRepository)(nil).After), ctx, afterID, limit)
+}
+
+// LastID mocks base method.
+func (m *MockEventsRepository) LastID(arg0 context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastID", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LastID indicates an expected call of LastID.
+func (mr *MockEventsRepositoryMockRecorder) LastID(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastID", reflect.TypeOf((*MockEventsRepository)(nil).LastID), arg0)
+}
+
+// DeleteBefore mocks base method.
+func (m *MockEventsRepository) DeleteBefore(ctx context.Context, t time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBefore", ctx, t)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteBefore indicates an expected call of DeleteBefore.
+func (mr *MockEventsRepositoryMockRecorder) DeleteBefore(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBefore", reflect.TypeOf((*MockEventsRepository)(nil).DeleteBefore), ctx, t)
+}
@@ -51,33 +51,48 @@ func (mr *MockImagesRepositoryMockRecorder) Save(arg0, arg1 interface{}) *gomock
 }
 
 // All mocks base method.
-func (m *MockImagesRepository) All(arg0 context.Context) ([]model.OriginalResized, error) {
+func (m *MockImagesRepository) All(arg0 context.Context, arg1 model.ImageFilter) ([]model.OriginalResized, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "All", arg0)
+	ret := m.ctrl.Call(m, "All", arg0, arg1)
 	ret0, _ := ret[0].([]model.OriginalResized)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // All indicates an expected call of All.
-func (mr *MockImagesRepositoryMockRecorder) All(arg0 interface{}) *gomock.Call {
+func (mr *MockImagesRepositoryMockRecorder) All(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "All", reflect.TypeOf((*MockImagesRepository)(nil).All), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "All", reflect.TypeOf((*MockImagesRepository)(nil).All), arg0, arg1)
+}
+
+// Originals mocks base method.
+func (m *MockImagesRepository) Originals(arg0 context.Context, arg1 model.ImageFilter) ([]model.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Originals", arg0, arg1)
+	ret0, _ := ret[0].([]model.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Originals indicates an expected call of Originals.
+func (mr *MockImagesRepositoryMockRecorder) Originals(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Originals", reflect.TypeOf((*MockImagesRepository)(nil).Originals), arg0, arg1)
 }
 
 // OnlyResized mocks base method.
-func (m *MockImagesRepository) OnlyResized(arg0 context.Context) ([]model.Image, error) {
+func (m *MockImagesRepository) OnlyResized(arg0 context.Context, arg1 model.ImageFilter) ([]model.Image, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "OnlyResized", arg0)
+	ret := m.ctrl.Call(m, "OnlyResized", arg0, arg1)
 	ret0, _ := ret[0].([]model.Image)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // OnlyResized indicates an expected call of OnlyResized.
-func (mr *MockImagesRepositoryMockRecorder) OnlyResized(arg0 interface{}) *gomock.Call {
+func (mr *MockImagesRepositoryMockRecorder) OnlyResized(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnlyResized", reflect.TypeOf((*MockImagesRepository)(nil).OnlyResized), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnlyResized", reflect.TypeOf((*MockImagesRepository)(nil).OnlyResized), arg0, arg1)
 }
 
 // GetOne mocks base method.
@@ -94,3 +109,91 @@ func (mr *MockImagesRepositoryMockRecorder) GetOne(arg0, arg1 interface{}) *gomo
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOne", reflect.TypeOf((*MockImagesRepository)(nil).GetOne), arg0, arg1)
 }
+
+// Variants mocks base method.
+func (m *MockImagesRepository) Variants(ctx context.Context, originalID int) ([]model.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Variants", ctx, originalID)
+	ret0, _ := ret[0].([]model.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Variants indicates an expected call of Variants.
+func (mr *MockImagesRepositoryMockRecorder) Variants(ctx, originalID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Variants", reflect.TypeOf((*MockImagesRepository)(nil).Variants), ctx, originalID)
+}
+
+// Similar mocks base method.
+func (m *MockImagesRepository) Similar(ctx context.Context, id, maxDistance int) ([]model.SimilarImage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Similar", ctx, id, maxDistance)
+	ret0, _ := ret[0].([]model.SimilarImage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Similar indicates an expected call of Similar.
+func (mr *MockImagesRepositoryMockRecorder) Similar(ctx, id, maxDistance interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Similar", reflect.TypeOf((*MockImagesRepository)(nil).Similar), ctx, id, maxDistance)
+}
+
+// OriginalsToBackfill mocks base method.
+func (m *MockImagesRepository) OriginalsToBackfill(arg0 context.Context) ([]model.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OriginalsToBackfill", arg0)
+	ret0, _ := ret[0].([]model.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OriginalsToBackfill indicates an expected call of OriginalsToBackfill.
+func (mr *MockImagesRepositoryMockRecorder) OriginalsToBackfill(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OriginalsToBackfill", reflect.TypeOf((*MockImagesRepository)(nil).OriginalsToBackfill), arg0)
+}
+
+// SetPlaceholder mocks base method.
+func (m *MockImagesRepository) SetPlaceholder(ctx context.Context, id int, blurHash, lqip string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPlaceholder", ctx, id, blurHash, lqip)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPlaceholder indicates an expected call of SetPlaceholder.
+func (mr *MockImagesRepositoryMockRecorder) SetPlaceholder(ctx, id, blurHash, lqip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPlaceholder", reflect.TypeOf((*MockImagesRepository)(nil).SetPlaceholder), ctx, id, blurHash, lqip)
+}
+
+// SetPerceptualHash mocks base method.
+func (m *MockImagesRepository) SetPerceptualHash(ctx context.Context, id int, hash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPerceptualHash", ctx, id, hash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPerceptualHash indicates an expected call of SetPerceptualHash.
+func (mr *MockImagesRepositoryMockRecorder) SetPerceptualHash(ctx, id, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPerceptualHash", reflect.TypeOf((*MockImagesRepository)(nil).SetPerceptualHash), ctx, id, hash)
+}
+
+// Delete mocks base method.
+func (m *MockImagesRepository) Delete(ctx context.Context, id int) ([]model.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, id)
+	ret0, _ := ret[0].([]model.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockImagesRepositoryMockRecorder) Delete(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockImagesRepository)(nil).Delete), ctx, id)
+}
@@ -0,0 +1,110 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: model\jobs.go
+
+// Package mock_model is a generated GoMock package.
+package mock_model
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	model "github.com/imager/src/model"
+)
+
+// MockJobsRepository is a mock of JobsRepository interface.
+type MockJobsRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockJobsRepositoryMockRecorder
+}
+
+// MockJobsRepositoryMockRecorder is the mock recorder for MockJobsRepository.
+type MockJobsRepositoryMockRecorder struct {
+	mock *MockJobsRepository
+}
+
+// NewMockJobsRepository creates a new mock instance.
+func NewMockJobsRepository(ctrl *gomock.Controller) *MockJobsRepository {
+	mock := &MockJobsRepository{ctrl: ctrl}
+	mock.recorder = &MockJobsRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockJobsRepository) EXPECT() *MockJobsRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Enqueue mocks base method.
+func (m *MockJobsRepository) Enqueue(arg0 context.Context, arg1 model.ResizeParams) (model.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", arg0, arg1)
+	ret0, _ := ret[0].(model.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Enqueue indicates an expected call of Enqueue.
+func (mr *MockJobsRepositoryMockRecorder) Enqueue(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockJobsRepository)(nil).Enqueue), arg0, arg1)
+}
+
+// Get mocks base method.
+func (m *MockJobsRepository) Get(ctx context.Context, id int) (model.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, id)
+	ret0, _ := ret[0].(model.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockJobsRepositoryMockRecorder) Get(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockJobsRepository)(nil).Get), ctx, id)
+}
+
+// Claim mocks base method.
+func (m *MockJobsRepository) Claim(ctx context.Context, maxAttempts int) (model.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Claim", ctx, maxAttempts)
+	ret0, _ := ret[0].(model.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Claim indicates an expected call of Claim.
+func (mr *MockJobsRepositoryMockRecorder) Claim(ctx, maxAttempts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Claim", reflect.TypeOf((*MockJobsRepository)(nil).Claim), ctx, maxAttempts)
+}
+
+// Complete mocks base method.
+func (m *MockJobsRepository) Complete(ctx context.Context, id int, result model.OriginalResized) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Complete", ctx, id, result)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Complete indicates an expected call of Complete.
+func (mr *MockJobsRepositoryMockRecorder) Complete(ctx, id, result interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Complete", reflect.TypeOf((*MockJobsRepository)(nil).Complete), ctx, id, result)
+}
+
+// Fail mocks base method.
+func (m *MockJobsRepository) Fail(ctx context.Context, id int, errMsg string, retryAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fail", ctx, id, errMsg, retryAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Fail indicates an expected call of Fail.
+func (mr *MockJobsRepositoryMockRecorder) Fail(ctx, id, errMsg, retryAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*MockJobsRepository)(nil).Fail), ctx, id, errMsg, retryAt)
+}
@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: model\keys.go
+
+// Package mock_model is a generated GoMock package.
+package mock_model
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	model "github.com/imager/src/model"
+)
+
+// MockAPIKeysRepository is a mock of APIKeysRepository interface.
+type MockAPIKeysRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeysRepositoryMockRecorder
+}
+
+// MockAPIKeysRepositoryMockRecorder is the mock recorder for MockAPIKeysRepository.
+type MockAPIKeysRepositoryMockRecorder struct {
+	mock *MockAPIKeysRepository
+}
+
+// NewMockAPIKeysRepository creates a new mock instance.
+func NewMockAPIKeysRepository(ctrl *gomock.Controller) *MockAPIKeysRepository {
+	mock := &MockAPIKeysRepository{ctrl: ctrl}
+	mock.recorder = &MockAPIKeysRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeysRepository) EXPECT() *MockAPIKeysRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockAPIKeysRepository) Create(ctx context.Context, key model.APIKey, hash string) (model.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, key, hash)
+	ret0, _ := ret[0].(model.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockAPIKeysRepositoryMockRecorder) Create(ctx, key, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAPIKeysRepository)(nil).Create), ctx, key, hash)
+}
+
+// ByHash mocks base method.
+func (m *MockAPIKeysRepository) ByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ByHash", ctx, hash)
+	ret0, _ := ret[0].(model.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ByHash indicates an expected call of ByHash.
+func (mr *MockAPIKeysRepositoryMockRecorder) ByHash(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ByHash", reflect.TypeOf((*MockAPIKeysRepository)(nil).ByHash), ctx, hash)
+}
+
+// Revoke mocks base method.
+func (m *MockAPIKeysRepository) Revoke(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockAPIKeysRepositoryMockRecorder) Revoke(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockAPIKeysRepository)(nil).Revoke), ctx, id)
+}
@@ -0,0 +1,81 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: model\quotas.go
+
+// Package mock_model is a generated GoMock package.
+package mock_model
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	model "github.com/imager/src/model"
+)
+
+// MockQuotasRepository is a mock of QuotasRepository interface.
+type MockQuotasRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuotasRepositoryMockRecorder
+}
+
+// MockQuotasRepositoryMockRecorder is the mock recorder for MockQuotasRepository.
+type MockQuotasRepositoryMockRecorder struct {
+	mock *MockQuotasRepository
+}
+
+// NewMockQuotasRepository creates a new mock instance.
+func NewMockQuotasRepository(ctrl *gomock.Controller) *MockQuotasRepository {
+	mock := &MockQuotasRepository{ctrl: ctrl}
+	mock.recorder = &MockQuotasRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQuotasRepository) EXPECT() *MockQuotasRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Quota mocks base method.
+func (m *MockQuotasRepository) Quota(arg0 context.Context) (model.Quota, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Quota", arg0)
+	ret0, _ := ret[0].(model.Quota)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Quota indicates an expected call of Quota.
+func (mr *MockQuotasRepositoryMockRecorder) Quota(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Quota", reflect.TypeOf((*MockQuotasRepository)(nil).Quota), arg0)
+}
+
+// Lock mocks base method.
+func (m *MockQuotasRepository) Lock(arg0 context.Context) (model.Quota, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lock", arg0)
+	ret0, _ := ret[0].(model.Quota)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Lock indicates an expected call of Lock.
+func (mr *MockQuotasRepositoryMockRecorder) Lock(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockQuotasRepository)(nil).Lock), arg0)
+}
+
+// Usage mocks base method.
+func (m *MockQuotasRepository) Usage(arg0 context.Context) (model.Usage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Usage", arg0)
+	ret0, _ := ret[0].(model.Usage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Usage indicates an expected call of Usage.
+func (mr *MockQuotasRepositoryMockRecorder) Usage(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Usage", reflect.TypeOf((*MockQuotasRepository)(nil).Usage), arg0)
+}
@@ -0,0 +1,153 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: model\webhooks.go
+
+// Package mock_model is a generated GoMock package.
+package mock_model
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	model "github.com/imager/src/model"
+)
+
+// MockWebhooksRepository is a mock of WebhooksRepository interface.
+type MockWebhooksRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhooksRepositoryMockRecorder
+}
+
+// MockWebhooksRepositoryMockRecorder is the mock recorder for MockWebhooksRepository.
+type MockWebhooksRepositoryMockRecorder struct {
+	mock *MockWebhooksRepository
+}
+
+// NewMockWebhooksRepository creates a new mock instance.
+func NewMockWebhooksRepository(ctrl *gomock.Controller) *MockWebhooksRepository {
+	mock := &MockWebhooksRepository{ctrl: ctrl}
+	mock.recorder = &MockWebhooksRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhooksRepository) EXPECT() *MockWebhooksRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Subscribe mocks base method.
+func (m *MockWebhooksRepository) Subscribe(arg0 context.Context, arg1 model.Subscription) (model.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", arg0, arg1)
+	ret0, _ := ret[0].(model.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockWebhooksRepositoryMockRecorder) Subscribe(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockWebhooksRepository)(nil).Subscribe), arg0, arg1)
+}
+
+// Subscriptions mocks base method.
+func (m *MockWebhooksRepository) Subscriptions(arg0 context.Context) ([]model.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscriptions", arg0)
+	ret0, _ := ret[0].([]model.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subscriptions indicates an expected call of Subscriptions.
+func (mr *MockWebhooksRepositoryMockRecorder) Subscriptions(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscriptions", reflect.TypeOf((*MockWebhooksRepository)(nil).Subscriptions), arg0)
+}
+
+// Unsubscribe mocks base method.
+func (m *MockWebhooksRepository) Unsubscribe(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unsubscribe", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unsubscribe indicates an expected call of Unsubscribe.
+func (mr *MockWebhooksRepositoryMockRecorder) Unsubscribe(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unsubscribe", reflect.TypeOf((*MockWebhooksRepository)(nil).Unsubscribe), ctx, id)
+}
+
+// Publish mocks base method.
+func (m *MockWebhooksRepository) Publish(ctx context.Context, eventType string, payload []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", ctx, eventType, payload)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockWebhooksRepositoryMockRecorder) Publish(ctx, eventType, payload interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockWebhooksRepository)(nil).Publish), ctx, eventType, payload)
+}
+
+// Deliveries mocks base method.
+func (m *MockWebhooksRepository) Deliveries(ctx context.Context, subscriptionID int) ([]model.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deliveries", ctx, subscriptionID)
+	ret0, _ := ret[0].([]model.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Deliveries indicates an expected call of Deliveries.
+func (mr *MockWebhooksRepositoryMockRecorder) Deliveries(ctx, subscriptionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deliveries", reflect.TypeOf((*MockWebhooksRepository)(nil).Deliveries), ctx, subscriptionID)
+}
+
+// Claim mocks base method.
+func (m *MockWebhooksRepository) Claim(ctx context.Context, limit int) ([]model.PendingDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Claim", ctx, limit)
+	ret0, _ := ret[0].([]model.PendingDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Claim indicates an expected call of Claim.
+func (mr *MockWebhooksRepositoryMockRecorder) Claim(ctx, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Claim", reflect.TypeOf((*MockWebhooksRepository)(nil).Claim), ctx, limit)
+}
+
+// Delivered mocks base method.
+func (m *MockWebhooksRepository) Delivered(ctx context.Context, id, responseStatus int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delivered", ctx, id, responseStatus)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delivered indicates an expected call of Delivered.
+func (mr *MockWebhooksRepositoryMockRecorder) Delivered(ctx, id, responseStatus interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delivered", reflect.TypeOf((*MockWebhooksRepository)(nil).Delivered), ctx, id, responseStatus)
+}
+
+// Fail mocks base method.
+func (m *MockWebhooksRepository) Fail(ctx context.Context, id, responseStatus int, errMsg string, retryAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fail", ctx, id, responseStatus, errMsg, retryAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Fail indicates an expected call of Fail.
+func (mr *MockWebhooksRepositoryMockRecorder) Fail(ctx, id, responseStatus, errMsg, retryAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*MockWebhooksRepository)(nil).Fail), ctx, id, responseStatus, errMsg, retryAt)
+}
@@ -0,0 +1,10 @@
+package model
+
+import "errors"
+
+var (
+	// ErrNotFound is returned by repositories when requested entity doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned by repositories when entity conflicts with already stored one.
+	ErrConflict = errors.New("conflict")
+)
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// Types of image lifecycle events.
+const (
+	EventImageCreated = "image.created"
+	EventImageResized = "image.resized"
+	EventImageDeleted = "image.deleted"
+)
+
+// EventTypes contain all types of events.
+var EventTypes = []string{EventImageCreated, EventImageResized, EventImageDeleted}
+
+// Event describes change of image.
+type Event struct {
+	// ID grows with every event, so events can be resumed after the last seen one.
+	ID        int `json:",omitempty"`
+	Type      string
+	TenantID  string
+	Image     Image
+	CreatedAt time.Time
+}
+
+// EventsRepository describes methods for working with log of events in DB.
+type EventsRepository interface {
+	// Append adds event of tenant of ctx to the log and returns it with ID.
+	Append(context.Context, Event) (Event, error)
+	// After returns up to limit events of tenant of ctx following event afterID.
+	// Events of a tenant commit in id order, so events with lower ids can't appear later.
+	After(ctx context.Context, afterID, limit int) ([]Event, error)
+	// LastID returns ID of the latest event of tenant of ctx, 0 when there are none.
+	LastID(context.Context) (int, error)
+	// DeleteBefore removes events of all tenants created before t and returns their number.
+	DeleteBefore(ctx context.Context, t time.Time) (int64, error)
+}
@@ -1,6 +1,9 @@
 package model
 
-import "context"
+import (
+	"context"
+	"image/color"
+)
 
 // OriginalResized describes original and changed images.
 type OriginalResized struct {
@@ -10,16 +13,45 @@ type OriginalResized struct {
 
 // Image describes image.
 type Image struct {
-	ID          int
-	DownloadURL string
-	Resolution  string
-	OriginalID  int `json:",omitempty"`
+	ID             int
+	DownloadURL    string
+	Resolution     string
+	OriginalID     int      `json:",omitempty"`
+	BlurHash       string   `json:",omitempty"`
+	LQIP           string   `json:",omitempty"`
+	DominantColor  string   `json:",omitempty"`
+	Palette        []string `json:",omitempty"`
+	PerceptualHash string   `json:",omitempty"`
+	// Size is a size of encoded image in bytes.
+	Size int64 `json:",omitempty"`
+}
+
+// SimilarImage describes image and its Hamming distance to the image it was compared with.
+type SimilarImage struct {
+	Image
+	Distance int
+}
+
+// ImageFilter describes conditions images are listed by, zero value matches all images.
+type ImageFilter struct {
+	// NearColor keeps only images which dominant color is close to it.
+	NearColor *color.RGBA
 }
 
 // ImagesRepository describes methods for working with DB.
 type ImagesRepository interface {
 	Save(context.Context, Image) (int, error)
-	All(context.Context) ([]OriginalResized, error)
-	OnlyResized(context.Context) ([]Image, error)
+	All(context.Context, ImageFilter) ([]OriginalResized, error)
+	// Originals returns originals matching filter, including the ones without variants.
+	Originals(context.Context, ImageFilter) ([]Image, error)
+	OnlyResized(context.Context, ImageFilter) ([]Image, error)
 	GetOne(context.Context, int) (Image, error)
+	Variants(ctx context.Context, originalID int) ([]Image, error)
+	Similar(ctx context.Context, id int, maxDistance int) ([]SimilarImage, error)
+	// OriginalsToBackfill returns originals of all tenants stored without placeholder or perceptual hash.
+	OriginalsToBackfill(context.Context) ([]Image, error)
+	SetPlaceholder(ctx context.Context, id int, blurHash, lqip string) error
+	SetPerceptualHash(ctx context.Context, id int, hash string) error
+	// Delete deletes image along with its variants and returns deleted images.
+	Delete(ctx context.Context, id int) ([]Image, error)
 }
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// Statuses of jobs.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// ResizeParams describes resize of stored image.
+type ResizeParams struct {
+	ImageID int
+	Width   int
+	Height  int
+	Mode    string `json:",omitempty"`
+	Gravity string `json:",omitempty"`
+}
+
+// Job describes asynchronous resize of image.
+type Job struct {
+	ID       int
+	TenantID string
+	Status   string
+	Params   ResizeParams
+	Result   *OriginalResized `json:",omitempty"`
+	Error    string           `json:",omitempty"`
+	// Attempts is a number of times job was started.
+	Attempts  int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// JobsRepository describes methods for working with job queue in DB.
+type JobsRepository interface {
+	// Enqueue adds job of tenant of ctx to the queue.
+	Enqueue(context.Context, ResizeParams) (Job, error)
+	// Get returns job of tenant of ctx.
+	Get(ctx context.Context, id int) (Job, error)
+	// Claim marks the oldest job ready to run as running and returns it, ErrNotFound is returned when there are none.
+	// Abandoned jobs started maxAttempts times are failed instead of being claimed again.
+	Claim(ctx context.Context, maxAttempts int) (Job, error)
+	Complete(ctx context.Context, id int, result OriginalResized) error
+	// Fail stores error of job, the job is queued again at retryAt unless it's zero.
+	Fail(ctx context.Context, id int, errMsg string, retryAt time.Time) error
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scopes of API keys.
+const (
+	ScopeImagesRead   = "images:read"
+	ScopeImagesWrite  = "images:write"
+	ScopeImagesDelete = "images:delete"
+	// ScopeAdmin allows managing API keys and implies all other scopes.
+	ScopeAdmin = "admin"
+)
+
+// Scopes contains all known scopes.
+var Scopes = []string{ScopeImagesRead, ScopeImagesWrite, ScopeImagesDelete, ScopeAdmin}
+
+// ValidateScopes returns error when scopes are empty or contain unknown scope.
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope should be set")
+	}
+	for _, scope := range scopes {
+		if !knownScope(scope) {
+			return fmt.Errorf("unknown scope '%s', known scopes are: %s", scope, strings.Join(Scopes, ", "))
+		}
+	}
+	return nil
+}
+
+func knownScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey describes key clients are authenticated with, the key itself is never stored.
+type APIKey struct {
+	ID   int
+	Name string
+	// TenantID is a tenant requests authenticated with the key act on behalf of.
+	TenantID  string
+	Scopes    []string
+	CreatedAt time.Time
+}
+
+// HasScope reports whether key is allowed to access resources protected by scope.
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeysRepository describes methods for working with API keys in DB.
+type APIKeysRepository interface {
+	// Create stores key with sha256 hash of its secret.
+	Create(ctx context.Context, key APIKey, hash string) (APIKey, error)
+	// ByHash returns not revoked key by hash of its secret.
+	ByHash(ctx context.Context, hash string) (APIKey, error)
+	Revoke(ctx context.Context, id int) error
+}
@@ -0,0 +1,32 @@
+package model
+
+import "context"
+
+// Quota limits storage used by tenant, zero values mean no limit.
+type Quota struct {
+	MaxBytes  int64
+	MaxImages int
+}
+
+// Usage describes storage used by tenant.
+type Usage struct {
+	Bytes  int64
+	Images int
+}
+
+// Exceeds reports whether usage grown by images of bytes exceeds quota.
+func (q Quota) Exceeds(u Usage, images int, bytes int64) bool {
+	if q.MaxImages > 0 && u.Images+images > q.MaxImages {
+		return true
+	}
+	return q.MaxBytes > 0 && u.Bytes+bytes > q.MaxBytes
+}
+
+// QuotasRepository describes methods for working with quotas and usage of tenant of ctx.
+type QuotasRepository interface {
+	Quota(context.Context) (Quota, error)
+	// Lock returns quota like Quota and locks it until the transaction of ctx ends,
+	// so concurrent requests of the tenant check their usage one by one.
+	Lock(context.Context) (Quota, error)
+	Usage(context.Context) (Usage, error)
+}
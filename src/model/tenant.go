@@ -0,0 +1,32 @@
+package model
+
+import (
+	"context"
+	"regexp"
+)
+
+// DefaultTenant owns images of callers which don't belong to any tenant.
+const DefaultTenant = "default"
+
+// tenantIDPattern keeps tenant ids safe to use as storage prefix.
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9-]{1,64}$`)
+
+// ValidTenantID reports whether id consists of 1 to 64 lowercase letters, digits and dashes.
+func ValidTenantID(id string) bool {
+	return tenantIDPattern.MatchString(id)
+}
+
+type tenantKey struct{}
+
+// WithTenant returns copy of ctx with tenant id, repositories and storage are scoped by it.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns tenant id of ctx or DefaultTenant when it isn't set.
+func TenantFromContext(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(tenantKey{}).(string); ok && tenantID != "" {
+		return tenantID
+	}
+	return DefaultTenant
+}
@@ -0,0 +1,10 @@
+package model
+
+import "context"
+
+// Transactor runs functions in DB transactions.
+type Transactor interface {
+	// InTx runs fn in a transaction, repositories called with ctx of fn take part in it.
+	// The transaction is rolled back when fn returns an error.
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Statuses of webhook deliveries.
+const (
+	DeliveryPending   = "pending"
+	DeliverySending   = "sending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Subscription describes url which receives events of tenant.
+type Subscription struct {
+	ID       int
+	TenantID string
+	URL      string
+	Events   []string
+	// Secret signs callbacks, it's returned only when subscription is created.
+	Secret    string `json:",omitempty"`
+	CreatedAt time.Time
+}
+
+// Delivery describes callback sent to subscription.
+type Delivery struct {
+	ID             int
+	SubscriptionID int
+	Event          string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int
+	ResponseStatus int    `json:",omitempty"`
+	Error          string `json:",omitempty"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// PendingDelivery is a delivery claimed for sending along with target of its subscription.
+type PendingDelivery struct {
+	Delivery
+	URL    string
+	Secret string
+}
+
+// WebhooksRepository describes methods for working with webhook subscriptions and their outbox in DB.
+type WebhooksRepository interface {
+	// Subscribe saves subscription of tenant of ctx.
+	Subscribe(context.Context, Subscription) (Subscription, error)
+	Subscriptions(context.Context) ([]Subscription, error)
+	Unsubscribe(ctx context.Context, id int) error
+	// Publish adds delivery of payload to the outbox for every subscription of tenant of ctx to event type.
+	Publish(ctx context.Context, eventType string, payload []byte) error
+	// Deliveries returns the latest deliveries of subscription of tenant of ctx.
+	Deliveries(ctx context.Context, subscriptionID int) ([]Delivery, error)
+	// Claim marks up to limit deliveries ready to be sent as sending and returns them.
+	Claim(ctx context.Context, limit int) ([]PendingDelivery, error)
+	Delivered(ctx context.Context, id int, responseStatus int) error
+	// Fail stores error of delivery, it's sent again at retryAt unless it's zero.
+	Fail(ctx context.Context, id int, responseStatus int, errMsg string, retryAt time.Time) error
+}
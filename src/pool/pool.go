@@ -0,0 +1,132 @@
+// Package pool runs CPU-bound tasks on bounded number of workers.
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrQueueFull is returned when all workers are busy and queue has no room for the task.
+var ErrQueueFull = errors.New("processing queue is full")
+
+// ErrClosed is returned for tasks submitted after pool was closed.
+var ErrClosed = errors.New("processing pool is closed")
+
+const (
+	stateQueued int32 = iota
+	stateRunning
+	stateCanceled
+)
+
+type task struct {
+	ctx   context.Context
+	fn    func() error
+	done  chan error
+	state int32
+}
+
+// Pool runs tasks on fixed number of workers, tasks wait for a free worker in a bounded queue.
+type Pool struct {
+	queue chan *task
+	wg    sync.WaitGroup
+	// capacity is a number of tasks which can be running or queued at once.
+	capacity int32
+	inFlight int32
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New starts pool of workers with queue of queueDepth tasks.
+func New(workers, queueDepth int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueDepth < 0 {
+		queueDepth = 0
+	}
+	capacity := workers + queueDepth
+	p := &Pool{queue: make(chan *task, capacity), capacity: int32(capacity)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Do runs fn on one of workers and returns its error. ErrQueueFull is returned immediately
+// when the queue has no room. When ctx is done before fn started, fn is dropped and ctx error is returned,
+// once started fn runs to completion as image processing can't be interrupted.
+func (p *Pool) Do(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	t := &task{ctx: ctx, fn: fn, done: make(chan error, 1)}
+
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrClosed
+	}
+	if atomic.AddInt32(&p.inFlight, 1) > p.capacity {
+		atomic.AddInt32(&p.inFlight, -1)
+		p.mu.RUnlock()
+		return ErrQueueFull
+	}
+	p.queue <- t
+	p.mu.RUnlock()
+
+	select {
+	case err := <-t.done:
+		return err
+	case <-ctx.Done():
+		if atomic.CompareAndSwapInt32(&t.state, stateQueued, stateCanceled) {
+			return ctx.Err()
+		}
+		return <-t.done
+	}
+}
+
+// QueueDepth returns number of tasks waiting for a worker.
+func (p *Pool) QueueDepth() int {
+	return len(p.queue)
+}
+
+// InFlight returns number of tasks running or waiting for a worker.
+func (p *Pool) InFlight() int {
+	return int(atomic.LoadInt32(&p.inFlight))
+}
+
+// Close stops accepting tasks and waits until queued tasks are processed.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for t := range p.queue {
+		p.run(t)
+		atomic.AddInt32(&p.inFlight, -1)
+	}
+}
+
+func (p *Pool) run(t *task) {
+	if !atomic.CompareAndSwapInt32(&t.state, stateQueued, stateRunning) {
+		return
+	}
+	if err := t.ctx.Err(); err != nil {
+		t.done <- err
+		return
+	}
+	t.done <- t.fn()
+}
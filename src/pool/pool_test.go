@@ -0,0 +1,120 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoLimitsConcurrency(t *testing.T) {
+	const workers = 3
+	p := New(workers, 100)
+	defer p.Close()
+
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.Do(context.Background(), func() error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning > workers {
+		t.Fatalf("expected at most %d tasks running at once, got: %d", workers, maxRunning)
+	}
+}
+
+func TestDoQueueFull(t *testing.T) {
+	p := New(1, 1)
+	defer p.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go p.Do(context.Background(), func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	queued := make(chan error)
+	go func() {
+		queued <- p.Do(context.Background(), func() error { return nil })
+	}()
+	for p.InFlight() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := p.Do(context.Background(), func() error { return nil }); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected error is: %v but got: %v", ErrQueueFull, err)
+	}
+
+	close(release)
+	if err := <-queued; err != nil {
+		t.Fatalf("expected queued task to succeed, got: %v", err)
+	}
+}
+
+func TestDoCanceledWhileQueued(t *testing.T) {
+	p := New(1, 1)
+	defer p.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go p.Do(context.Background(), func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var called int32
+	done := make(chan error)
+	go func() {
+		done <- p.Do(ctx, func() error {
+			atomic.StoreInt32(&called, 1)
+			return nil
+		})
+	}()
+	for p.InFlight() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected error is: %v but got: %v", context.Canceled, err)
+	}
+
+	close(release)
+	p.Close()
+	if atomic.LoadInt32(&called) != 0 {
+		t.Fatal("expected canceled task not to run")
+	}
+}
+
+func TestClose(t *testing.T) {
+	p := New(1, 1)
+	p.Close()
+	if err := p.Do(context.Background(), func() error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected error is: %v but got: %v", ErrClosed, err)
+	}
+}
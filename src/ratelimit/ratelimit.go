@@ -0,0 +1,141 @@
+// Package ratelimit limits rate of requests per client.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/imager/src/auth"
+	"github.com/imager/src/handler/v1/apierror"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultRate is a number of requests per second every client can make.
+	DefaultRate = 10
+	// DefaultBurst is a number of requests client can make at once.
+	DefaultBurst = 20
+	// DefaultIPRate is a number of requests per second every IP address can make before authentication,
+	// it's higher than DefaultRate since clients behind NAT share address.
+	DefaultIPRate = 50
+	// DefaultIPBurst is a number of requests IP address can make at once.
+	DefaultIPBurst = 100
+
+	// idleTimeout is a time after which limiter of client which didn't make requests is dropped.
+	idleTimeout = 10 * time.Minute
+
+	codeRateLimited = "rate_limited"
+)
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter is a token bucket rate limiter keyed by API key or IP address of the client.
+type Limiter struct {
+	rate  rate.Limit
+	burst int
+	key   func(*http.Request) string
+
+	mu        sync.Mutex
+	clients   map[string]*client
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New creates new Limiter which allows r requests per second with bursts of burst requests.
+func New(r float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate.Limit(r),
+		burst:   burst,
+		key:     clientKey,
+		clients: map[string]*client{},
+		now:     time.Now,
+	}
+}
+
+// NewByIP creates new Limiter keyed by IP address only, it's used before authentication,
+// so requests with invalid keys are limited too.
+func NewByIP(r float64, burst int) *Limiter {
+	l := New(r, burst)
+	l.key = ipKey
+	return l
+}
+
+// Middleware rejects requests of clients which exceeded their rate with 429 and Retry-After header.
+// Limiter created by New should be used after auth.Service.Authenticate, otherwise clients are identified by IP address only.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, retryAfter := l.allow(l.key(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			data, statusCode := apierror.New(w, r, http.StatusTooManyRequests, codeRateLimited,
+				fmt.Sprintf("rate limit of %v requests per second exceeded", float64(l.rate)), nil)
+			apierror.Write(w, data, statusCode)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow takes token of client and returns false with time after which it's available when there are none.
+func (l *Limiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(l.rate, l.burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+
+	reservation := c.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Second
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// sweep drops limiters of idle clients, so memory doesn't grow with number of clients ever seen.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTimeout {
+		return
+	}
+	l.lastSweep = now
+	for key, c := range l.clients {
+		if now.Sub(c.lastSeen) > idleTimeout {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// clientKey identifies client by API key it's authenticated with or by its IP address.
+// X-Forwarded-For isn't trusted as clients can set it to anything.
+func clientKey(r *http.Request) string {
+	if key, ok := auth.KeyFromContext(r.Context()); ok {
+		return "key:" + strconv.Itoa(key.ID)
+	}
+	return ipKey(r)
+}
+
+// ipKey identifies client by its IP address.
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
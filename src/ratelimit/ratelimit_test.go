@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/imager/src/auth"
+	"github.com/imager/src/model"
+)
+
+func TestMiddleware(t *testing.T) {
+	now := time.Now()
+	l := New(1, 2)
+	l.now = func() time.Time { return now }
+
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(r *http.Request) *http.Response {
+		wr := httptest.NewRecorder()
+		handler.ServeHTTP(wr, r)
+		return wr.Result()
+	}
+
+	fromIP := httptest.NewRequest(http.MethodGet, "/api/v1/images", nil)
+	fromIP.RemoteAddr = "10.0.0.1:1234"
+
+	for i := 0; i < 2; i++ {
+		if res := serve(fromIP); res.StatusCode != http.StatusOK {
+			t.Fatalf("expected request %d within burst to pass, got: %d", i, res.StatusCode)
+		}
+	}
+
+	res := serve(fromIP)
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status code is: %d but got: %d", http.StatusTooManyRequests, res.StatusCode)
+	}
+	if retryAfter := res.Header.Get("Retry-After"); retryAfter != "1" {
+		t.Fatalf("expected Retry-After is: 1 but got: %s", retryAfter)
+	}
+
+	withKey := fromIP.WithContext(auth.WithKey(fromIP.Context(), model.APIKey{ID: 1}))
+	if res := serve(withKey); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected request with key to be limited separately from IP, got: %d", res.StatusCode)
+	}
+
+	now = now.Add(time.Second)
+	if res := serve(fromIP); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected request to pass after token is refilled, got: %d", res.StatusCode)
+	}
+}
+
+func TestNewByIP(t *testing.T) {
+	l := NewByIP(1, 1)
+
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i, key := range []int{1, 2} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/images", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r = r.WithContext(auth.WithKey(r.Context(), model.APIKey{ID: key}))
+		wr := httptest.NewRecorder()
+		handler.ServeHTTP(wr, r)
+
+		expected := http.StatusOK
+		if i > 0 {
+			expected = http.StatusTooManyRequests
+		}
+		if statusCode := wr.Result().StatusCode; statusCode != expected {
+			t.Fatalf("expected status code of request %d is: %d but got: %d", i, expected, statusCode)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	now := time.Now()
+	l := New(1, 1)
+	l.now = func() time.Time { return now }
+
+	l.allow("ip:10.0.0.1")
+	now = now.Add(2 * idleTimeout)
+	l.allow("ip:10.0.0.2")
+
+	if _, ok := l.clients["ip:10.0.0.1"]; ok {
+		t.Fatal("expected limiter of idle client to be dropped")
+	}
+	if _, ok := l.clients["ip:10.0.0.2"]; !ok {
+		t.Fatal("expected limiter of active client to be kept")
+	}
+}
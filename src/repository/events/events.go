@@ -0,0 +1,112 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/imager/src/metrics"
+	"github.com/imager/src/model"
+	"github.com/imager/src/repository/postgres"
+)
+
+// eventsLock namespaces advisory locks of events from other advisory locks.
+const eventsLock = 1
+
+// insertEventQuery takes lock of the tenant which is held until the transaction ends before the id is assigned,
+// so events of a tenant commit in id order. Otherwise readers could move past the id of an event
+// of a transaction which hasn't committed yet and never see it.
+// Times of events come from the db clock at insertion, so they grow together with ids.
+const (
+	insertEventQuery = `INSERT INTO events (tenant_id, type, image, created_at)
+	 SELECT $1::varchar, $2::varchar, $3::jsonb, clock_timestamp() AT TIME ZONE 'UTC' FROM (SELECT pg_advisory_xact_lock($4::int, hashtext($1))) lock
+	 RETURNING id, created_at`
+	eventsAfterQuery  = "SELECT id, tenant_id, type, image, created_at FROM events WHERE tenant_id = $1 AND id > $2 ORDER BY id LIMIT $3"
+	lastEventIDQuery  = "SELECT coalesce(max(id), 0) FROM events WHERE tenant_id = $1"
+	deleteEventsQuery = "DELETE FROM events WHERE created_at < $1"
+)
+
+// Repo contains db session.
+type Repo struct {
+	db *sql.DB
+}
+
+// NewRepo creates new Repo struct with db session.
+func NewRepo(db *sql.DB) *Repo {
+	return &Repo{db}
+}
+
+// Append adds event of tenant of ctx to the log and returns it with ID.
+func (r *Repo) Append(ctx context.Context, event model.Event) (model.Event, error) {
+	defer metrics.ObserveQuery("events", "Append")()
+
+	const errMsg = "inserting of event '%s' of image %d to db failed with error: %v"
+	img, err := json.Marshal(event.Image)
+	if err != nil {
+		return model.Event{}, fmt.Errorf(errMsg, event.Type, event.Image.ID, err)
+	}
+	event.TenantID = model.TenantFromContext(ctx)
+	if err := postgres.Conn(ctx, r.db).QueryRowContext(ctx, insertEventQuery, event.TenantID, event.Type, img, eventsLock).Scan(&event.ID, &event.CreatedAt); err != nil {
+		return model.Event{}, fmt.Errorf(errMsg, event.Type, event.Image.ID, err)
+	}
+	return event, nil
+}
+
+// After returns up to limit events of tenant of ctx following event afterID.
+func (r *Repo) After(ctx context.Context, afterID, limit int) ([]model.Event, error) {
+	defer metrics.ObserveQuery("events", "After")()
+
+	rows, err := r.db.QueryContext(ctx, eventsAfterQuery, model.TenantFromContext(ctx), afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting events after %d: %v", afterID, err)
+	}
+	defer rows.Close()
+
+	var events []model.Event
+	for rows.Next() {
+		var (
+			event model.Event
+			img   []byte
+		)
+		if err := rows.Scan(&event.ID, &event.TenantID, &event.Type, &img, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning event: %v", err)
+		}
+		if err := json.Unmarshal(img, &event.Image); err != nil {
+			return nil, fmt.Errorf("error decoding image of event %d: %v", event.ID, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting events after %d: %v", afterID, err)
+	}
+	return events, nil
+}
+
+// LastID returns ID of the latest committed event of tenant of ctx, 0 when there are none.
+// Events of the tenant committed later get higher ids, so streams starting after it don't miss any.
+func (r *Repo) LastID(ctx context.Context) (int, error) {
+	defer metrics.ObserveQuery("events", "LastID")()
+
+	var id int
+	if err := r.db.QueryRowContext(ctx, lastEventIDQuery, model.TenantFromContext(ctx)).Scan(&id); err != nil {
+		return 0, fmt.Errorf("error getting last event id: %v", err)
+	}
+	return id, nil
+}
+
+// DeleteBefore removes events of all tenants created before t and returns their number.
+func (r *Repo) DeleteBefore(ctx context.Context, t time.Time) (int64, error) {
+	defer metrics.ObserveQuery("events", "DeleteBefore")()
+
+	res, err := r.db.ExecContext(ctx, deleteEventsQuery, t.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error deleting events before %s: %v", t, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error deleting events before %s: %v", t, err)
+	}
+	return n, nil
+}
@@ -4,24 +4,61 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
 
+	"github.com/imager/src/imgproc/palette"
+	"github.com/imager/src/metrics"
 	"github.com/imager/src/model"
+	"github.com/imager/src/repository/postgres"
+	"github.com/lib/pq"
 )
 
 const (
 	allImagesQuery = `SELECT
 	 A.id AS originalID,
-	 A.download_url AS original_download_url, 
-	 A.resolution AS original_resolution, 
-	 B.id AS resizedID, 
-	 B.download_url AS resized_download_url, 
-	 B.resolution AS resized_resolution 
-	 FROM images A, images B WHERE A.id = B.original_id`
-
-	onlyResizedImagesQuery           = "SELECT id, download_url, resolution FROM images WHERE original_id IS NOT NULL"
-	oneByID                          = "SELECT id, download_url, resolution FROM images WHERE id = $1"
-	insertImageWithReferenceQuery    = "INSERT INTO images (download_url, resolution, original_id) VALUES ($1, $2, $3) RETURNING id"
-	insertImageWithoutReferenceQuery = "INSERT INTO images (download_url, resolution) VALUES ($1, $2) RETURNING id"
+	 A.download_url AS original_download_url,
+	 A.resolution AS original_resolution,
+	 A.blurhash AS original_blurhash,
+	 A.lqip AS original_lqip,
+	 A.dominant_color AS original_dominant_color,
+	 A.palette AS original_palette,
+	 B.id AS resizedID,
+	 B.download_url AS resized_download_url,
+	 B.resolution AS resized_resolution,
+	 B.dominant_color AS resized_dominant_color,
+	 B.palette AS resized_palette
+	 FROM images A, images B WHERE A.id = B.original_id AND A.tenant_id = $1`
+
+	originalsQuery                   = "SELECT id, download_url, resolution, blurhash, lqip, dominant_color, palette FROM images WHERE original_id IS NULL AND tenant_id = $1"
+	onlyResizedImagesQuery           = "SELECT id, download_url, resolution, dominant_color, palette FROM images WHERE original_id IS NOT NULL AND tenant_id = $1"
+	oneByID                          = "SELECT id, download_url, resolution, original_id, blurhash, lqip, dominant_color, palette, phash, size_bytes FROM images WHERE id = $1 AND tenant_id = $2"
+	insertImageWithReferenceQuery    = "INSERT INTO images (download_url, resolution, original_id, blurhash, lqip, dominant_color, palette, phash, tenant_id, size_bytes) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id"
+	insertImageWithoutReferenceQuery = "INSERT INTO images (download_url, resolution, blurhash, lqip, dominant_color, palette, phash, tenant_id, size_bytes) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id"
+	originalsToBackfillQuery         = "SELECT id, download_url, resolution, blurhash, phash FROM images WHERE original_id IS NULL AND (blurhash = '' OR phash IS NULL) ORDER BY id"
+	updatePlaceholderQuery           = "UPDATE images SET blurhash = $2, lqip = $3 WHERE id = $1"
+	updatePerceptualHashQuery        = "UPDATE images SET phash = $2 WHERE id = $1"
+	variantsQuery                    = "SELECT id, download_url, resolution, original_id, dominant_color, palette FROM images WHERE original_id = $1 AND tenant_id = $2 ORDER BY id"
+
+	// deleteImageQuery deletes image $1 of tenant $2 along with its variants.
+	deleteImageQuery = "DELETE FROM images WHERE tenant_id = $2 AND (id = $1 OR original_id = $1) RETURNING id, download_url, resolution, original_id"
+
+	// similarImagesQuery returns originals of tenant $3 which perceptual hash differs from the hash of image $1 by at most $2 bits.
+	similarImagesQuery = `SELECT * FROM (
+	 SELECT
+	 B.id, B.download_url, B.resolution, B.blurhash, B.lqip, B.dominant_color, B.palette, B.phash,
+	 length(replace((A.phash # B.phash)::bit(64)::text, '0', '')) AS distance
+	 FROM images A, images B
+	 WHERE A.id = $1 AND A.tenant_id = $3 AND B.tenant_id = $3 AND B.id <> A.id AND B.original_id IS NULL AND B.phash IS NOT NULL
+	) similar WHERE distance <= $2 ORDER BY distance, id`
+
+	// nearColorCondition keeps rows of table %[1]s which dominant color is within Euclidean RGB distance $%[5]d from ($%[2]d, $%[3]d, $%[4]d).
+	nearColorCondition = ` AND sqrt(
+	 power(((%[1]s.dominant_color >> 16) & 255) - $%[2]d, 2) +
+	 power(((%[1]s.dominant_color >> 8) & 255) - $%[3]d, 2) +
+	 power((%[1]s.dominant_color & 255) - $%[4]d, 2)) <= $%[5]d`
+
+	// nearColorMaxDistance is a distance in RGB space within which colors are considered near.
+	nearColorMaxDistance = 64
 )
 
 // Repo contains db session.
@@ -34,26 +71,44 @@ func NewRepo(db *sql.DB) *Repo {
 	return &Repo{db}
 }
 
-// Save inserts new image with or without reference.
+// Save inserts new image with or without reference, the image belongs to tenant of ctx.
 func (r *Repo) Save(ctx context.Context, img model.Image) (int, error) {
-	const errMsg = "inserting of '%v' to db failed with error: %v"
+	defer metrics.ObserveQuery("images", "Save")()
+
+	const errMsg = "inserting of '%v' to db failed with error: %w"
+	dominantColor, err := colorToInt(img.DominantColor)
+	if err != nil {
+		return 0, fmt.Errorf(errMsg, img, err)
+	}
+	colors := img.Palette
+	if colors == nil {
+		colors = []string{}
+	}
+	hash, err := hashToInt(img.PerceptualHash)
+	if err != nil {
+		return 0, fmt.Errorf(errMsg, img, err)
+	}
+	tenantID := model.TenantFromContext(ctx)
 	var id int
 	if img.OriginalID != 0 {
-		if err := r.db.QueryRowContext(ctx, insertImageWithReferenceQuery, img.DownloadURL, img.Resolution, img.OriginalID).Scan(&id); err != nil {
-			return 0, fmt.Errorf(errMsg, img, err)
+		if err := postgres.Conn(ctx, r.db).QueryRowContext(ctx, insertImageWithReferenceQuery, img.DownloadURL, img.Resolution, img.OriginalID, img.BlurHash, img.LQIP, dominantColor, pq.Array(colors), hash, tenantID, img.Size).Scan(&id); err != nil {
+			return 0, fmt.Errorf(errMsg, img, postgres.ModelError(err))
 		}
 		return id, nil
 	}
-	if err := r.db.QueryRowContext(ctx, insertImageWithoutReferenceQuery, img.DownloadURL, img.Resolution).Scan(&id); err != nil {
-		return 0, fmt.Errorf(errMsg, img, err)
+	if err := postgres.Conn(ctx, r.db).QueryRowContext(ctx, insertImageWithoutReferenceQuery, img.DownloadURL, img.Resolution, img.BlurHash, img.LQIP, dominantColor, pq.Array(colors), hash, tenantID, img.Size).Scan(&id); err != nil {
+		return 0, fmt.Errorf(errMsg, img, postgres.ModelError(err))
 	}
 	return id, nil
 }
 
-// All returns all images.
-func (r *Repo) All(ctx context.Context) ([]model.OriginalResized, error) {
+// All returns all images of tenant of ctx matching filter.
+func (r *Repo) All(ctx context.Context, filter model.ImageFilter) ([]model.OriginalResized, error) {
+	defer metrics.ObserveQuery("images", "All")()
+
 	const errMsg = "error getting all images from DB: %v"
-	rows, err := r.db.QueryContext(ctx, allImagesQuery)
+	query, args := applyFilter(allImagesQuery, "A", filter, model.TenantFromContext(ctx))
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf(errMsg, err)
 	}
@@ -61,26 +116,76 @@ func (r *Repo) All(ctx context.Context) ([]model.OriginalResized, error) {
 
 	res := []model.OriginalResized{}
 	for rows.Next() {
-		var originalResized model.OriginalResized
+		var (
+			originalResized model.OriginalResized
+			originalColor   sql.NullInt32
+			resizedColor    sql.NullInt32
+		)
 		if err := rows.Scan(
 			&originalResized.Original.ID,
 			&originalResized.Original.DownloadURL,
 			&originalResized.Original.Resolution,
+			&originalResized.Original.BlurHash,
+			&originalResized.Original.LQIP,
+			&originalColor,
+			pq.Array(&originalResized.Original.Palette),
 			&originalResized.Resized.ID,
 			&originalResized.Resized.DownloadURL,
 			&originalResized.Resized.Resolution,
+			&resizedColor,
+			pq.Array(&originalResized.Resized.Palette),
 		); err != nil {
 			return nil, fmt.Errorf(errMsg, err)
 		}
+		originalResized.Original.DominantColor = intToColor(originalColor)
+		originalResized.Resized.DominantColor = intToColor(resizedColor)
 		res = append(res, originalResized)
 	}
 	return res, nil
 }
 
-// OnlyResized returns only resized images.
-func (r *Repo) OnlyResized(ctx context.Context) ([]model.Image, error) {
+// Originals returns original images of tenant of ctx matching filter, including the ones without variants.
+func (r *Repo) Originals(ctx context.Context, filter model.ImageFilter) ([]model.Image, error) {
+	defer metrics.ObserveQuery("images", "Originals")()
+
+	const errMsg = "error getting original images from DB: %v"
+	query, args := applyFilter(originalsQuery, "images", filter, model.TenantFromContext(ctx))
+	rows, err := r.db.QueryContext(ctx, query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+	defer rows.Close()
+
+	res := []model.Image{}
+	for rows.Next() {
+		var (
+			image         model.Image
+			dominantColor sql.NullInt32
+		)
+		if err := rows.Scan(
+			&image.ID,
+			&image.DownloadURL,
+			&image.Resolution,
+			&image.BlurHash,
+			&image.LQIP,
+			&dominantColor,
+			pq.Array(&image.Palette),
+		); err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+		image.DominantColor = intToColor(dominantColor)
+		res = append(res, image)
+	}
+	return res, rows.Err()
+}
+
+// OnlyResized returns only resized images of tenant of ctx matching filter.
+func (r *Repo) OnlyResized(ctx context.Context, filter model.ImageFilter) ([]model.Image, error) {
+	defer metrics.ObserveQuery("images", "OnlyResized")()
+
 	const errMsg = "error getting only resized images from DB: %v"
-	rows, err := r.db.QueryContext(ctx, onlyResizedImagesQuery)
+	query, args := applyFilter(onlyResizedImagesQuery, "images", filter, model.TenantFromContext(ctx))
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf(errMsg, err)
 	}
@@ -88,24 +193,270 @@ func (r *Repo) OnlyResized(ctx context.Context) ([]model.Image, error) {
 
 	res := []model.Image{}
 	for rows.Next() {
-		var image model.Image
+		var (
+			image         model.Image
+			dominantColor sql.NullInt32
+		)
 		if err := rows.Scan(
 			&image.ID,
 			&image.DownloadURL,
 			&image.Resolution,
+			&dominantColor,
+			pq.Array(&image.Palette),
 		); err != nil {
 			return nil, fmt.Errorf(errMsg, err)
 		}
+		image.DominantColor = intToColor(dominantColor)
 		res = append(res, image)
 	}
 	return res, nil
 }
 
-// GetOne returns specific image by it's ID.
+// GetOne returns specific image of tenant of ctx by it's ID.
 func (r *Repo) GetOne(ctx context.Context, id int) (model.Image, error) {
-	var image model.Image
-	if err := r.db.QueryRowContext(ctx, oneByID, id).Scan(&image.ID, &image.DownloadURL, &image.Resolution); err != nil {
-		return model.Image{}, fmt.Errorf("error getting image by ID: %d, error: %v", id, err)
+	defer metrics.ObserveQuery("images", "GetOne")()
+
+	var (
+		image         model.Image
+		originalID    sql.NullInt32
+		dominantColor sql.NullInt32
+		hash          sql.NullInt64
+	)
+	if err := r.db.QueryRowContext(ctx, oneByID, id, model.TenantFromContext(ctx)).Scan(
+		&image.ID,
+		&image.DownloadURL,
+		&image.Resolution,
+		&originalID,
+		&image.BlurHash,
+		&image.LQIP,
+		&dominantColor,
+		pq.Array(&image.Palette),
+		&hash,
+		&image.Size,
+	); err != nil {
+		return model.Image{}, fmt.Errorf("error getting image by ID: %d, error: %w", id, postgres.ModelError(err))
 	}
+	image.OriginalID = int(originalID.Int32)
+	image.DominantColor = intToColor(dominantColor)
+	image.PerceptualHash = intToHash(hash)
 	return image, nil
 }
+
+// Similar returns originals which perceptual hash is within maxDistance from the hash of specific image.
+func (r *Repo) Similar(ctx context.Context, id int, maxDistance int) ([]model.SimilarImage, error) {
+	defer metrics.ObserveQuery("images", "Similar")()
+
+	const errMsg = "error getting images similar to image by ID: %d, error: %v"
+	rows, err := r.db.QueryContext(ctx, similarImagesQuery, id, maxDistance, model.TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, id, err)
+	}
+	defer rows.Close()
+
+	res := []model.SimilarImage{}
+	for rows.Next() {
+		var (
+			image         model.SimilarImage
+			dominantColor sql.NullInt32
+			hash          sql.NullInt64
+		)
+		if err := rows.Scan(
+			&image.ID,
+			&image.DownloadURL,
+			&image.Resolution,
+			&image.BlurHash,
+			&image.LQIP,
+			&dominantColor,
+			pq.Array(&image.Palette),
+			&hash,
+			&image.Distance,
+		); err != nil {
+			return nil, fmt.Errorf(errMsg, id, err)
+		}
+		image.DominantColor = intToColor(dominantColor)
+		image.PerceptualHash = intToHash(hash)
+		res = append(res, image)
+	}
+	return res, rows.Err()
+}
+
+// Variants returns all images resized from specific original.
+func (r *Repo) Variants(ctx context.Context, originalID int) ([]model.Image, error) {
+	defer metrics.ObserveQuery("images", "Variants")()
+
+	const errMsg = "error getting variants of image by ID: %d, error: %v"
+	rows, err := r.db.QueryContext(ctx, variantsQuery, originalID, model.TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, originalID, err)
+	}
+	defer rows.Close()
+
+	res := []model.Image{}
+	for rows.Next() {
+		var (
+			image         model.Image
+			dominantColor sql.NullInt32
+		)
+		if err := rows.Scan(
+			&image.ID,
+			&image.DownloadURL,
+			&image.Resolution,
+			&image.OriginalID,
+			&dominantColor,
+			pq.Array(&image.Palette),
+		); err != nil {
+			return nil, fmt.Errorf(errMsg, originalID, err)
+		}
+		image.DominantColor = intToColor(dominantColor)
+		res = append(res, image)
+	}
+	return res, rows.Err()
+}
+
+// OriginalsToBackfill returns original images of all tenants which don't have blurhash and lqip or perceptual hash yet.
+func (r *Repo) OriginalsToBackfill(ctx context.Context) ([]model.Image, error) {
+	defer metrics.ObserveQuery("images", "OriginalsToBackfill")()
+
+	const errMsg = "error getting originals to backfill from DB: %v"
+	rows, err := r.db.QueryContext(ctx, originalsToBackfillQuery)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+	defer rows.Close()
+
+	res := []model.Image{}
+	for rows.Next() {
+		var (
+			image model.Image
+			hash  sql.NullInt64
+		)
+		if err := rows.Scan(
+			&image.ID,
+			&image.DownloadURL,
+			&image.Resolution,
+			&image.BlurHash,
+			&hash,
+		); err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+		image.PerceptualHash = intToHash(hash)
+		res = append(res, image)
+	}
+	return res, rows.Err()
+}
+
+// SetPlaceholder updates blurhash and lqip of specific image.
+func (r *Repo) SetPlaceholder(ctx context.Context, id int, blurHash, lqip string) error {
+	defer metrics.ObserveQuery("images", "SetPlaceholder")()
+
+	const errMsg = "error updating placeholder of image by ID: %d, error: %w"
+	res, err := r.db.ExecContext(ctx, updatePlaceholderQuery, id, blurHash, lqip)
+	if err != nil {
+		return fmt.Errorf(errMsg, id, postgres.ModelError(err))
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf(errMsg, id, model.ErrNotFound)
+	}
+	return nil
+}
+
+// SetPerceptualHash updates perceptual hash of specific image.
+func (r *Repo) SetPerceptualHash(ctx context.Context, id int, hash string) error {
+	defer metrics.ObserveQuery("images", "SetPerceptualHash")()
+
+	const errMsg = "error updating perceptual hash of image by ID: %d, error: %w"
+	v, err := hashToInt(hash)
+	if err != nil {
+		return fmt.Errorf(errMsg, id, err)
+	}
+	res, err := r.db.ExecContext(ctx, updatePerceptualHashQuery, id, v)
+	if err != nil {
+		return fmt.Errorf(errMsg, id, postgres.ModelError(err))
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf(errMsg, id, model.ErrNotFound)
+	}
+	return nil
+}
+
+// Delete deletes image of tenant of ctx along with its variants and returns deleted images.
+func (r *Repo) Delete(ctx context.Context, id int) ([]model.Image, error) {
+	defer metrics.ObserveQuery("images", "Delete")()
+
+	const errMsg = "error deleting image by ID: %d, error: %w"
+	rows, err := postgres.Conn(ctx, r.db).QueryContext(ctx, deleteImageQuery, id, model.TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, id, postgres.ModelError(err))
+	}
+	defer rows.Close()
+
+	var images []model.Image
+	for rows.Next() {
+		var (
+			img        model.Image
+			originalID sql.NullInt32
+		)
+		if err := rows.Scan(&img.ID, &img.DownloadURL, &img.Resolution, &originalID); err != nil {
+			return nil, fmt.Errorf(errMsg, id, err)
+		}
+		img.OriginalID = int(originalID.Int32)
+		images = append(images, img)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf(errMsg, id, postgres.ModelError(err))
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf(errMsg, id, model.ErrNotFound)
+	}
+	return images, nil
+}
+
+// applyFilter appends filter conditions on table to query and returns it with arguments,
+// args are arguments query already has.
+func applyFilter(query, table string, filter model.ImageFilter, args ...interface{}) (string, []interface{}) {
+	if filter.NearColor == nil {
+		return query, args
+	}
+	c := filter.NearColor
+	n := len(args)
+	return query + fmt.Sprintf(nearColorCondition, table, n+1, n+2, n+3, n+4),
+		append(args, int(c.R), int(c.G), int(c.B), nearColorMaxDistance)
+}
+
+// colorToInt converts #rrggbb color to integer representation stored in DB.
+func colorToInt(hex string) (sql.NullInt32, error) {
+	if hex == "" {
+		return sql.NullInt32{}, nil
+	}
+	c, err := palette.ParseHex(hex)
+	if err != nil {
+		return sql.NullInt32{}, err
+	}
+	return sql.NullInt32{Int32: int32(c.R)<<16 | int32(c.G)<<8 | int32(c.B), Valid: true}, nil
+}
+
+func intToColor(v sql.NullInt32) string {
+	if !v.Valid {
+		return ""
+	}
+	return fmt.Sprintf("#%06x", v.Int32)
+}
+
+// hashToInt converts hex encoded perceptual hash to signed integer stored in DB, bits are kept as is.
+func hashToInt(hex string) (sql.NullInt64, error) {
+	if hex == "" {
+		return sql.NullInt64{}, nil
+	}
+	v, err := strconv.ParseUint(hex, 16, 64)
+	if err != nil {
+		return sql.NullInt64{}, fmt.Errorf("invalid perceptual hash '%s': %v", hex, err)
+	}
+	return sql.NullInt64{Int64: int64(v), Valid: true}, nil
+}
+
+func intToHash(v sql.NullInt64) string {
+	if !v.Valid {
+		return ""
+	}
+	return fmt.Sprintf("%016x", uint64(v.Int64))
+}
@@ -0,0 +1,173 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/imager/src/metrics"
+	"github.com/imager/src/model"
+)
+
+const (
+	insertJobQuery = "INSERT INTO jobs (tenant_id, params) VALUES ($1, $2) RETURNING id, status, created_at, updated_at"
+	jobByIDQuery   = "SELECT id, tenant_id, status, params, result, error, attempts, created_at, updated_at FROM jobs WHERE id = $1 AND tenant_id = $2"
+
+	// claimJobQuery takes the oldest job ready to run, jobs locked by other workers are skipped.
+	// Jobs running longer than the lease are considered abandoned by crashed workers and are taken again
+	// unless they were started $2 times already.
+	claimJobQuery = `UPDATE jobs SET status = 'running', attempts = attempts + 1, updated_at = now()
+	 WHERE id = (
+	  SELECT id FROM jobs
+	  WHERE (status = 'queued' AND run_at <= now())
+	   OR (status = 'running' AND updated_at < now() - $1 * interval '1 second' AND attempts < $2)
+	  ORDER BY run_at, id
+	  FOR UPDATE SKIP LOCKED
+	  LIMIT 1
+	 )
+	 RETURNING id, tenant_id, status, params, attempts, created_at, updated_at`
+
+	completeJobQuery = "UPDATE jobs SET status = 'succeeded', result = $2, error = '', updated_at = now() WHERE id = $1"
+	retryJobQuery    = "UPDATE jobs SET status = 'queued', error = $2, run_at = $3, updated_at = now() WHERE id = $1"
+	failJobQuery     = "UPDATE jobs SET status = 'failed', error = $2, updated_at = now() WHERE id = $1"
+
+	// failAbandonedJobsQuery fails jobs which crashed workers on every attempt, so they don't stay running forever.
+	failAbandonedJobsQuery = `UPDATE jobs SET status = 'failed', error = 'abandoned by worker on every attempt', updated_at = now()
+	 WHERE status = 'running' AND updated_at < now() - $1 * interval '1 second' AND attempts >= $2`
+
+	// lease is a time after which running job is considered abandoned.
+	lease = 10 * time.Minute
+)
+
+// Repo contains db session.
+type Repo struct {
+	db *sql.DB
+}
+
+// NewRepo creates new Repo struct with db session.
+func NewRepo(db *sql.DB) *Repo {
+	return &Repo{db}
+}
+
+// Enqueue adds job of tenant of ctx to the queue.
+func (r *Repo) Enqueue(ctx context.Context, params model.ResizeParams) (model.Job, error) {
+	defer metrics.ObserveQuery("jobs", "Enqueue")()
+
+	const errMsg = "inserting of job '%v' to db failed with error: %v"
+	b, err := json.Marshal(params)
+	if err != nil {
+		return model.Job{}, fmt.Errorf(errMsg, params, err)
+	}
+	job := model.Job{TenantID: model.TenantFromContext(ctx), Params: params}
+	if err := r.db.QueryRowContext(ctx, insertJobQuery, job.TenantID, b).Scan(&job.ID, &job.Status, &job.CreatedAt, &job.UpdatedAt); err != nil {
+		return model.Job{}, fmt.Errorf(errMsg, params, err)
+	}
+	return job, nil
+}
+
+// Get returns job of tenant of ctx.
+func (r *Repo) Get(ctx context.Context, id int) (model.Job, error) {
+	defer metrics.ObserveQuery("jobs", "Get")()
+
+	const errMsg = "error getting job by ID: %d, error: %w"
+	var (
+		job            model.Job
+		params, result []byte
+	)
+	err := r.db.QueryRowContext(ctx, jobByIDQuery, id, model.TenantFromContext(ctx)).Scan(
+		&job.ID,
+		&job.TenantID,
+		&job.Status,
+		&params,
+		&result,
+		&job.Error,
+		&job.Attempts,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Job{}, fmt.Errorf(errMsg, id, model.ErrNotFound)
+	}
+	if err != nil {
+		return model.Job{}, fmt.Errorf(errMsg, id, err)
+	}
+	if err := json.Unmarshal(params, &job.Params); err != nil {
+		return model.Job{}, fmt.Errorf(errMsg, id, err)
+	}
+	if result != nil {
+		job.Result = &model.OriginalResized{}
+		if err := json.Unmarshal(result, job.Result); err != nil {
+			return model.Job{}, fmt.Errorf(errMsg, id, err)
+		}
+	}
+	return job, nil
+}
+
+// Claim marks the oldest job ready to run as running and returns it.
+// Abandoned jobs started maxAttempts times are failed instead of being claimed again.
+func (r *Repo) Claim(ctx context.Context, maxAttempts int) (model.Job, error) {
+	defer metrics.ObserveQuery("jobs", "Claim")()
+
+	const errMsg = "error claiming job, error: %w"
+	if _, err := r.db.ExecContext(ctx, failAbandonedJobsQuery, lease.Seconds(), maxAttempts); err != nil {
+		return model.Job{}, fmt.Errorf(errMsg, err)
+	}
+
+	var (
+		job    model.Job
+		params []byte
+	)
+	err := r.db.QueryRowContext(ctx, claimJobQuery, lease.Seconds(), maxAttempts).Scan(
+		&job.ID,
+		&job.TenantID,
+		&job.Status,
+		&params,
+		&job.Attempts,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Job{}, fmt.Errorf(errMsg, model.ErrNotFound)
+	}
+	if err != nil {
+		return model.Job{}, fmt.Errorf(errMsg, err)
+	}
+	if err := json.Unmarshal(params, &job.Params); err != nil {
+		return model.Job{}, fmt.Errorf(errMsg, err)
+	}
+	return job, nil
+}
+
+// Complete stores result of job.
+func (r *Repo) Complete(ctx context.Context, id int, result model.OriginalResized) error {
+	defer metrics.ObserveQuery("jobs", "Complete")()
+
+	const errMsg = "error completing job by ID: %d, error: %v"
+	b, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf(errMsg, id, err)
+	}
+	if _, err := r.db.ExecContext(ctx, completeJobQuery, id, b); err != nil {
+		return fmt.Errorf(errMsg, id, err)
+	}
+	return nil
+}
+
+// Fail stores error of job, the job is queued again at retryAt unless it's zero.
+func (r *Repo) Fail(ctx context.Context, id int, errMsg string, retryAt time.Time) error {
+	defer metrics.ObserveQuery("jobs", "Fail")()
+
+	var err error
+	if retryAt.IsZero() {
+		_, err = r.db.ExecContext(ctx, failJobQuery, id, errMsg)
+	} else {
+		_, err = r.db.ExecContext(ctx, retryJobQuery, id, errMsg, retryAt)
+	}
+	if err != nil {
+		return fmt.Errorf("error failing job by ID: %d, error: %v", id, err)
+	}
+	return nil
+}
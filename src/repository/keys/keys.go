@@ -0,0 +1,77 @@
+package keys
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/imager/src/metrics"
+	"github.com/imager/src/model"
+	"github.com/imager/src/repository/postgres"
+	"github.com/lib/pq"
+)
+
+const (
+	insertKeyQuery = "INSERT INTO api_keys (name, tenant_id, key_hash, scopes) VALUES ($1, $2, $3, $4) RETURNING id, created_at"
+	keyByHashQuery = "SELECT id, name, tenant_id, scopes, created_at FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL"
+	revokeKeyQuery = "UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL AND tenant_id = $2"
+)
+
+// Repo contains db session.
+type Repo struct {
+	db *sql.DB
+}
+
+// NewRepo creates new Repo struct with db session.
+func NewRepo(db *sql.DB) *Repo {
+	return &Repo{db}
+}
+
+// Create inserts new key.
+func (r *Repo) Create(ctx context.Context, key model.APIKey, hash string) (model.APIKey, error) {
+	defer metrics.ObserveQuery("keys", "Create")()
+
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	if key.TenantID == "" {
+		key.TenantID = model.DefaultTenant
+	}
+	if err := r.db.QueryRowContext(ctx, insertKeyQuery, key.Name, key.TenantID, hash, pq.Array(scopes)).Scan(&key.ID, &key.CreatedAt); err != nil {
+		return model.APIKey{}, fmt.Errorf("inserting of key '%s' to db failed with error: %w", key.Name, postgres.ModelError(err))
+	}
+	return key, nil
+}
+
+// ByHash returns not revoked key by hash of its secret.
+func (r *Repo) ByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	defer metrics.ObserveQuery("keys", "ByHash")()
+
+	var key model.APIKey
+	if err := r.db.QueryRowContext(ctx, keyByHashQuery, hash).Scan(
+		&key.ID,
+		&key.Name,
+		&key.TenantID,
+		pq.Array(&key.Scopes),
+		&key.CreatedAt,
+	); err != nil {
+		return model.APIKey{}, fmt.Errorf("error getting key by hash, error: %w", postgres.ModelError(err))
+	}
+	return key, nil
+}
+
+// Revoke marks key of tenant of ctx as revoked, revoked keys can't be used anymore.
+func (r *Repo) Revoke(ctx context.Context, id int) error {
+	defer metrics.ObserveQuery("keys", "Revoke")()
+
+	const errMsg = "error revoking key by ID: %d, error: %w"
+	res, err := r.db.ExecContext(ctx, revokeKeyQuery, id, model.TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf(errMsg, id, postgres.ModelError(err))
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf(errMsg, id, model.ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Package postgres contains helpers shared by repositories.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/imager/src/config"
+	"github.com/imager/src/model"
+)
+
+// uniqueViolation is a postgres error code of unique constraint violation.
+const uniqueViolation = "23505"
+
+// Open returns db session configured by cfg.
+func Open(cfg config.DB) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.ConnString())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+	return db, nil
+}
+
+// ModelError converts errors of db driver to model errors, so callers don't depend on the driver.
+func ModelError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ErrNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return fmt.Errorf("%w: %s", model.ErrConflict, pqErr.Message)
+	}
+	return err
+}
+
+// Querier is implemented by both *sql.DB and *sql.Tx.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+// Conn returns transaction of ctx started by Transactor, or db when there is none.
+func Conn(ctx context.Context, db *sql.DB) Querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// Transactor runs functions in transactions of db.
+type Transactor struct {
+	db *sql.DB
+}
+
+// NewTransactor creates new Transactor with db session.
+func NewTransactor(db *sql.DB) *Transactor {
+	return &Transactor{db}
+}
+
+// InTx runs fn in a transaction which repositories join when called with ctx of fn.
+// fn is a part of the outer transaction when ctx already has one.
+func (t *Transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed with error: %v)", err, rbErr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
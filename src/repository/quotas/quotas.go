@@ -0,0 +1,67 @@
+package quotas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/imager/src/metrics"
+	"github.com/imager/src/model"
+	"github.com/imager/src/repository/postgres"
+)
+
+const (
+	quotaQuery     = "SELECT max_bytes, max_images FROM tenant_quotas WHERE tenant_id = $1"
+	lockQuotaQuery = quotaQuery + " FOR UPDATE"
+	usageQuery     = "SELECT count(*), coalesce(sum(size_bytes), 0) FROM images WHERE tenant_id = $1"
+)
+
+// Repo contains db session.
+type Repo struct {
+	db *sql.DB
+}
+
+// NewRepo creates new Repo struct with db session.
+func NewRepo(db *sql.DB) *Repo {
+	return &Repo{db}
+}
+
+// Quota returns quota of tenant of ctx, tenants without quota aren't limited.
+func (r *Repo) Quota(ctx context.Context) (model.Quota, error) {
+	defer metrics.ObserveQuery("quotas", "Quota")()
+
+	return r.quota(ctx, quotaQuery)
+}
+
+// Lock returns quota of tenant of ctx and locks it until the transaction of ctx ends.
+func (r *Repo) Lock(ctx context.Context) (model.Quota, error) {
+	defer metrics.ObserveQuery("quotas", "Lock")()
+
+	return r.quota(ctx, lockQuotaQuery)
+}
+
+func (r *Repo) quota(ctx context.Context, query string) (model.Quota, error) {
+	tenantID := model.TenantFromContext(ctx)
+	var quota model.Quota
+	err := postgres.Conn(ctx, r.db).QueryRowContext(ctx, query, tenantID).Scan(&quota.MaxBytes, &quota.MaxImages)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Quota{}, nil
+	}
+	if err != nil {
+		return model.Quota{}, fmt.Errorf("error getting quota of tenant '%s', error: %v", tenantID, err)
+	}
+	return quota, nil
+}
+
+// Usage returns number and size of images stored by tenant of ctx.
+func (r *Repo) Usage(ctx context.Context) (model.Usage, error) {
+	defer metrics.ObserveQuery("quotas", "Usage")()
+
+	tenantID := model.TenantFromContext(ctx)
+	var usage model.Usage
+	if err := postgres.Conn(ctx, r.db).QueryRowContext(ctx, usageQuery, tenantID).Scan(&usage.Images, &usage.Bytes); err != nil {
+		return model.Usage{}, fmt.Errorf("error getting usage of tenant '%s', error: %v", tenantID, err)
+	}
+	return usage, nil
+}
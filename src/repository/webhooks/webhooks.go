@@ -0,0 +1,214 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/imager/src/metrics"
+	"github.com/imager/src/model"
+	"github.com/imager/src/repository/postgres"
+	"github.com/lib/pq"
+)
+
+const (
+	insertSubscriptionQuery = "INSERT INTO webhook_subscriptions (tenant_id, url, events, secret) VALUES ($1, $2, $3, $4) RETURNING id, created_at"
+	subscriptionsQuery      = "SELECT id, tenant_id, url, events, created_at FROM webhook_subscriptions WHERE tenant_id = $1 ORDER BY id"
+	deleteSubscriptionQuery = "DELETE FROM webhook_subscriptions WHERE id = $1 AND tenant_id = $2"
+
+	publishQuery = `INSERT INTO webhook_deliveries (subscription_id, event, payload)
+	 SELECT id, $2, $3 FROM webhook_subscriptions WHERE tenant_id = $1 AND $2 = ANY(events)`
+
+	// deliveriesQuery returns the latest deliveries of subscription $1 of tenant $2.
+	deliveriesQuery = `SELECT d.id, d.subscription_id, d.event, d.payload, d.status, d.attempts, d.response_status, d.error, d.created_at, d.updated_at
+	 FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+	 WHERE d.subscription_id = $1 AND s.tenant_id = $2
+	 ORDER BY d.id DESC LIMIT 100`
+
+	// claimQuery takes deliveries ready to be sent, deliveries locked by other dispatchers are skipped.
+	// Deliveries sending longer than the lease are considered abandoned by crashed dispatchers and are taken again.
+	claimQuery = `UPDATE webhook_deliveries d SET status = 'sending', attempts = d.attempts + 1, updated_at = now()
+	 FROM webhook_subscriptions s
+	 WHERE s.id = d.subscription_id AND d.id IN (
+	  SELECT id FROM webhook_deliveries
+	  WHERE (status = 'pending' AND next_attempt_at <= now()) OR (status = 'sending' AND updated_at < now() - $2 * interval '1 second')
+	  ORDER BY next_attempt_at, id
+	  FOR UPDATE SKIP LOCKED
+	  LIMIT $1
+	 )
+	 RETURNING d.id, d.subscription_id, d.event, d.payload, d.status, d.attempts, d.created_at, d.updated_at, s.url, s.secret`
+
+	deliveredQuery = "UPDATE webhook_deliveries SET status = 'delivered', response_status = $2, error = '', updated_at = now() WHERE id = $1"
+	retryQuery     = "UPDATE webhook_deliveries SET status = 'pending', response_status = $2, error = $3, next_attempt_at = $4, updated_at = now() WHERE id = $1"
+	failQuery      = "UPDATE webhook_deliveries SET status = 'failed', response_status = $2, error = $3, updated_at = now() WHERE id = $1"
+
+	// lease is a time after which delivery being sent is considered abandoned.
+	lease = 5 * time.Minute
+)
+
+// Repo contains db session.
+type Repo struct {
+	db *sql.DB
+}
+
+// NewRepo creates new Repo struct with db session.
+func NewRepo(db *sql.DB) *Repo {
+	return &Repo{db}
+}
+
+// Subscribe saves subscription of tenant of ctx.
+func (r *Repo) Subscribe(ctx context.Context, sub model.Subscription) (model.Subscription, error) {
+	defer metrics.ObserveQuery("webhooks", "Subscribe")()
+
+	sub.TenantID = model.TenantFromContext(ctx)
+	if err := r.db.QueryRowContext(ctx, insertSubscriptionQuery, sub.TenantID, sub.URL, pq.Array(sub.Events), sub.Secret).Scan(&sub.ID, &sub.CreatedAt); err != nil {
+		return model.Subscription{}, fmt.Errorf("inserting of subscription '%s' to db failed with error: %v", sub.URL, err)
+	}
+	return sub, nil
+}
+
+// Subscriptions returns subscriptions of tenant of ctx without their secrets.
+func (r *Repo) Subscriptions(ctx context.Context) ([]model.Subscription, error) {
+	defer metrics.ObserveQuery("webhooks", "Subscriptions")()
+
+	rows, err := r.db.QueryContext(ctx, subscriptionsQuery, model.TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("error getting subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	subs := []model.Subscription{}
+	for rows.Next() {
+		var sub model.Subscription
+		if err := rows.Scan(&sub.ID, &sub.TenantID, &sub.URL, pq.Array(&sub.Events), &sub.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning subscription: %v", err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting subscriptions: %v", err)
+	}
+	return subs, nil
+}
+
+// Unsubscribe deletes subscription of tenant of ctx along with its deliveries.
+func (r *Repo) Unsubscribe(ctx context.Context, id int) error {
+	defer metrics.ObserveQuery("webhooks", "Unsubscribe")()
+
+	const errMsg = "error deleting subscription by ID: %d, error: %w"
+	res, err := r.db.ExecContext(ctx, deleteSubscriptionQuery, id, model.TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf(errMsg, id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf(errMsg, id, model.ErrNotFound)
+	}
+	return nil
+}
+
+// Publish adds delivery of payload to the outbox for every subscription of tenant of ctx to event type.
+func (r *Repo) Publish(ctx context.Context, eventType string, payload []byte) error {
+	defer metrics.ObserveQuery("webhooks", "Publish")()
+
+	if _, err := postgres.Conn(ctx, r.db).ExecContext(ctx, publishQuery, model.TenantFromContext(ctx), eventType, payload); err != nil {
+		return fmt.Errorf("error publishing event '%s': %v", eventType, err)
+	}
+	return nil
+}
+
+// Deliveries returns the latest deliveries of subscription of tenant of ctx.
+func (r *Repo) Deliveries(ctx context.Context, subscriptionID int) ([]model.Delivery, error) {
+	defer metrics.ObserveQuery("webhooks", "Deliveries")()
+
+	rows, err := r.db.QueryContext(ctx, deliveriesQuery, subscriptionID, model.TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("error getting deliveries of subscription %d: %v", subscriptionID, err)
+	}
+	defer rows.Close()
+
+	deliveries := []model.Delivery{}
+	for rows.Next() {
+		var d model.Delivery
+		if err := rows.Scan(
+			&d.ID,
+			&d.SubscriptionID,
+			&d.Event,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.ResponseStatus,
+			&d.Error,
+			&d.CreatedAt,
+			&d.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning delivery: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting deliveries of subscription %d: %v", subscriptionID, err)
+	}
+	return deliveries, nil
+}
+
+// Claim marks up to limit deliveries ready to be sent as sending and returns them.
+func (r *Repo) Claim(ctx context.Context, limit int) ([]model.PendingDelivery, error) {
+	defer metrics.ObserveQuery("webhooks", "Claim")()
+
+	rows, err := r.db.QueryContext(ctx, claimQuery, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("error claiming deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	var deliveries []model.PendingDelivery
+	for rows.Next() {
+		var d model.PendingDelivery
+		if err := rows.Scan(
+			&d.ID,
+			&d.SubscriptionID,
+			&d.Event,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.CreatedAt,
+			&d.UpdatedAt,
+			&d.URL,
+			&d.Secret,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning delivery: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error claiming deliveries: %v", err)
+	}
+	return deliveries, nil
+}
+
+// Delivered marks delivery as sent.
+func (r *Repo) Delivered(ctx context.Context, id int, responseStatus int) error {
+	defer metrics.ObserveQuery("webhooks", "Delivered")()
+
+	if _, err := r.db.ExecContext(ctx, deliveredQuery, id, responseStatus); err != nil {
+		return fmt.Errorf("error marking delivery %d as delivered: %v", id, err)
+	}
+	return nil
+}
+
+// Fail stores error of delivery, it's sent again at retryAt unless it's zero.
+func (r *Repo) Fail(ctx context.Context, id int, responseStatus int, errMsg string, retryAt time.Time) error {
+	defer metrics.ObserveQuery("webhooks", "Fail")()
+
+	var err error
+	if retryAt.IsZero() {
+		_, err = r.db.ExecContext(ctx, failQuery, id, responseStatus, errMsg)
+	} else {
+		_, err = r.db.ExecContext(ctx, retryQuery, id, responseStatus, errMsg, retryAt)
+	}
+	if err != nil {
+		return fmt.Errorf("error failing delivery %d: %v", id, err)
+	}
+	return nil
+}
@@ -1,24 +1,183 @@
 package router
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/gorilla/mux"
+	"github.com/imager/src/auth"
+	"github.com/imager/src/config"
+	health "github.com/imager/src/handler/health"
+	events "github.com/imager/src/handler/v1/events"
 	handler "github.com/imager/src/handler/v1/images"
+	keys "github.com/imager/src/handler/v1/keys"
+	webhooks "github.com/imager/src/handler/v1/webhooks"
+	"github.com/imager/src/metrics"
 	"github.com/imager/src/model"
+	"github.com/imager/src/pool"
+	"github.com/imager/src/ratelimit"
 	"github.com/imager/src/web/downloader"
+	"github.com/imager/src/web/recorder"
 	"github.com/imager/src/web/uploader"
 )
 
-// New returns new router.
-func New(imgRepo model.ImagesRepository, uploadSvc uploader.Service, downloadSvc downloader.Service) *mux.Router {
+type options struct {
+	rate     float64
+	burst    int
+	ipRate   float64
+	ipBurst  int
+	webhooks model.WebhooksRepository
+	events   model.EventsRepository
+	streams  []events.Option
+	health   []health.Option
+	handler  []handler.Option
+}
+
+// Option configures router.
+type Option func(*options)
+
+// WithRateLimit sets number of requests per second and burst every client is allowed to make.
+func WithRateLimit(rate float64, burst int) Option {
+	return func(o *options) {
+		o.rate, o.burst = rate, burst
+	}
+}
+
+// WithIPRateLimit sets number of requests per second and burst every IP address is allowed to make before authentication.
+func WithIPRateLimit(rate float64, burst int) Option {
+	return func(o *options) {
+		o.ipRate, o.ipBurst = rate, burst
+	}
+}
+
+// WithLimits sets sizes of images handlers agree to process.
+func WithLimits(limits config.Limits) Option {
+	return func(o *options) {
+		o.handler = append(o.handler, handler.WithLimits(limits))
+	}
+}
+
+// WithQuotas enables enforcement of tenant quotas.
+func WithQuotas(quotas model.QuotasRepository) Option {
+	return func(o *options) {
+		o.handler = append(o.handler, handler.WithQuotas(quotas))
+	}
+}
+
+// WithPool makes images be processed on the pool.
+func WithPool(p *pool.Pool) Option {
+	return func(o *options) {
+		o.handler = append(o.handler, handler.WithPool(p))
+	}
+}
+
+// WithJobs enables asynchronous resizes queued to jobs.
+func WithJobs(jobs model.JobsRepository) Option {
+	return func(o *options) {
+		o.handler = append(o.handler, handler.WithJobs(jobs))
+	}
+}
+
+// WithWebhooks enables webhook subscriptions and publishing of image events to them.
+func WithWebhooks(repo model.WebhooksRepository) Option {
+	return func(o *options) {
+		o.webhooks = repo
+		o.handler = append(o.handler, handler.WithWebhooks(repo))
+	}
+}
+
+// WithEvents enables log of image events and its stream.
+func WithEvents(repo model.EventsRepository) Option {
+	return func(o *options) {
+		o.events = repo
+		o.handler = append(o.handler, handler.WithEvents(repo))
+	}
+}
+
+// WithTransactor makes images be stored together with their events in one transaction.
+func WithTransactor(tx model.Transactor) Option {
+	return func(o *options) {
+		o.handler = append(o.handler, handler.WithTransactor(tx))
+	}
+}
+
+// WithWriteTimeout makes streams end before write timeout of the server interrupts them.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.streams = append(o.streams, events.WithMaxDuration(d*9/10))
+	}
+}
+
+// WithShutdown ends streams when done is closed, so they don't hold shutdown of the server.
+func WithShutdown(done <-chan struct{}) Option {
+	return func(o *options) {
+		o.streams = append(o.streams, events.WithDone(done))
+	}
+}
+
+// WithReadinessCheck makes /readyz report the process isn't ready while check of dependency fails.
+func WithReadinessCheck(name string, check health.Check) Option {
+	return func(o *options) {
+		o.health = append(o.health, health.WithCheck(name, check))
+	}
+}
+
+// New returns new router, every /api/v1 endpoint requires API key with a scope,
+// /healthz, /readyz and /metrics are open to probes of the orchestrator and scrapers.
+func New(imgRepo model.ImagesRepository, keysRepo model.APIKeysRepository, uploadSvc uploader.Service, downloadSvc downloader.Service, opts ...Option) *mux.Router {
+	o := options{rate: ratelimit.DefaultRate, burst: ratelimit.DefaultBurst, ipRate: ratelimit.DefaultIPRate, ipBurst: ratelimit.DefaultIPBurst}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	router := mux.NewRouter()
-	imgSvcV1 := handler.NewService(imgRepo, uploadSvc, downloadSvc)
+	router.Use(recorder.Route)
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	healthSvc := health.NewService(o.health...)
+	router.HandleFunc("/healthz", healthSvc.Healthz).Methods("GET")
+	router.HandleFunc("/readyz", healthSvc.Readyz).Methods("GET")
+
+	imgSvcV1 := handler.NewService(imgRepo, uploadSvc, downloadSvc, o.handler...)
+	keysSvcV1 := keys.NewService(keysRepo)
 
 	apiV1 := router.PathPrefix("/api/v1").Subrouter()
+	apiV1.Use(ratelimit.NewByIP(o.ipRate, o.ipBurst).Middleware)
+	apiV1.Use(auth.New(keysRepo).Authenticate)
+	apiV1.Use(ratelimit.New(o.rate, o.burst).Middleware)
+
+	read := func(h http.HandlerFunc) http.HandlerFunc { return auth.RequireScope(model.ScopeImagesRead, h) }
+	write := func(h http.HandlerFunc) http.HandlerFunc { return auth.RequireScope(model.ScopeImagesWrite, h) }
+	del := func(h http.HandlerFunc) http.HandlerFunc { return auth.RequireScope(model.ScopeImagesDelete, h) }
+	admin := func(h http.HandlerFunc) http.HandlerFunc { return auth.RequireScope(model.ScopeAdmin, h) }
+
+	apiV1.HandleFunc("/images", read(imgSvcV1.All)).Methods("GET")
+	apiV1.HandleFunc("/images", write(imgSvcV1.Resize)).Methods("POST").Queries("height", "", "weight", "")
+	apiV1.HandleFunc("/images/batch", write(imgSvcV1.Batch)).Methods("POST").Queries("height", "", "weight", "")
+	apiV1.HandleFunc("/images/{id:[0-9]+}", read(imgSvcV1.GetByID)).Methods("GET")
+	apiV1.HandleFunc("/images/{id:[0-9]+}", del(imgSvcV1.Delete)).Methods("DELETE")
+	apiV1.HandleFunc("/images/{id:[0-9]+}/similar", read(imgSvcV1.Similar)).Methods("GET")
+	apiV1.HandleFunc("/images/{id:[0-9]+}/responsive", write(imgSvcV1.Responsive)).Methods("POST")
+	apiV1.HandleFunc("/images/{id}", write(imgSvcV1.ResizeByID)).Methods("POST").Queries("height", "", "weight", "")
+
+	apiV1.HandleFunc("/images/resized", read(imgSvcV1.OnlyResized)).Methods("GET")
+	apiV1.HandleFunc("/jobs/{id:[0-9]+}", read(imgSvcV1.Job)).Methods("GET")
+	apiV1.HandleFunc("/usage", read(imgSvcV1.Usage)).Methods("GET")
+	apiV1.HandleFunc("/export", read(imgSvcV1.Export)).Methods("GET")
 
-	apiV1.HandleFunc("/images", imgSvcV1.All).Methods("GET")
-	apiV1.HandleFunc("/images", imgSvcV1.Resize).Methods("POST").Queries("height", "", "weight", "")
-	apiV1.HandleFunc("/images/{id}", imgSvcV1.ResizeByID).Methods("POST").Queries("height", "", "weight", "")
+	apiV1.HandleFunc("/keys", admin(keysSvcV1.Issue)).Methods("POST")
+	apiV1.HandleFunc("/keys/{id:[0-9]+}", admin(keysSvcV1.Revoke)).Methods("DELETE")
 
-	apiV1.HandleFunc("/images/resized", imgSvcV1.OnlyResized).Methods("GET")
+	if o.events != nil {
+		eventsSvcV1 := events.NewService(o.events, o.streams...)
+		apiV1.HandleFunc("/events", read(eventsSvcV1.Stream)).Methods("GET")
+	}
+	if o.webhooks != nil {
+		webhooksSvcV1 := webhooks.NewService(o.webhooks)
+		apiV1.HandleFunc("/webhooks", admin(webhooksSvcV1.Subscribe)).Methods("POST")
+		apiV1.HandleFunc("/webhooks", admin(webhooksSvcV1.Subscriptions)).Methods("GET")
+		apiV1.HandleFunc("/webhooks/{id:[0-9]+}", admin(webhooksSvcV1.Unsubscribe)).Methods("DELETE")
+		apiV1.HandleFunc("/webhooks/{id:[0-9]+}/deliveries", admin(webhooksSvcV1.Deliveries)).Methods("GET")
+	}
 	return router
 }
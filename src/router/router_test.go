@@ -5,7 +5,7 @@ import (
 )
 
 func TestNew(t *testing.T) {
-	if New(nil, nil, nil) == nil {
+	if New(nil, nil, nil, nil) == nil {
 		t.Fatal("calling to New shouldn't return nil")
 	}
 }
@@ -2,47 +2,416 @@ package downloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
 )
 
+const (
+	// DefaultMaxBytes is a maximal size of downloaded body when it's not set explicitly.
+	DefaultMaxBytes = 32 << 20
+	// DefaultMaxRedirects is a maximal number of followed redirects when it's not set explicitly.
+	DefaultMaxRedirects = 5
+	// DefaultMaxRetries is a number of retries after transient failures when it's not set explicitly.
+	DefaultMaxRetries = 2
+	// DefaultRetryDelay is a delay before the first retry, every next one waits twice longer.
+	DefaultRetryDelay = 200 * time.Millisecond
+)
+
+// Timeouts describes timeouts of separate phases of the download.
+type Timeouts struct {
+	Dial           time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration
+}
+
+// DefaultTimeouts are used when timeouts aren't set explicitly.
+var DefaultTimeouts = Timeouts{
+	Dial:           5 * time.Second,
+	TLSHandshake:   5 * time.Second,
+	ResponseHeader: 10 * time.Second,
+}
+
+// DefaultAllowedContentTypes contain media types accepted when they aren't set explicitly,
+// objects uploaded without content type are served by s3 as binary/octet-stream.
+var DefaultAllowedContentTypes = []string{"image/*", "application/octet-stream", "binary/octet-stream"}
+
+var (
+	// ErrTooLarge is returned when response body exceeds maximal size.
+	ErrTooLarge = errors.New("response body is too large")
+	// ErrForbiddenURL is returned when scheme or host of the url isn't allowed.
+	ErrForbiddenURL = errors.New("url is not allowed")
+	// ErrForbiddenAddress is returned when host resolves to blocked network.
+	ErrForbiddenAddress = errors.New("address is not allowed")
+	// ErrUnsupportedContentType is returned when response media type isn't allowed.
+	ErrUnsupportedContentType = errors.New("content type is not allowed")
+
+	errTooManyRedirects = errors.New("too many redirects")
+)
+
+// DefaultAllowedSchemes are schemes allowed when they aren't set explicitly.
+var DefaultAllowedSchemes = []string{"http", "https"}
+
+// DefaultBlockedCIDRs contain private, loopback, link-local and other non public ranges
+// which shouldn't be reachable by user provided urls.
+var DefaultBlockedCIDRs = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+// DefaultBlockedNetworks are parsed DefaultBlockedCIDRs.
+var DefaultBlockedNetworks = mustParseCIDRs(DefaultBlockedCIDRs...)
+
 // Service describes donwloader interface.
 type Service interface {
 	Download(context.Context, string) ([]byte, error)
+	// Open returns response body without buffering it, caller should close it.
+	Open(context.Context, string) (io.ReadCloser, error)
 }
 
-type impl struct{}
+type impl struct {
+	client              *http.Client
+	maxBytes            int64
+	maxRedirects        int
+	maxRetries          int
+	retryDelay          time.Duration
+	timeouts            Timeouts
+	allowedSchemes      []string
+	allowedHosts        []string
+	allowedContentTypes []string
+	blockedNetworks     []*net.IPNet
+}
+
+// Option configures downloader.
+type Option func(*impl)
+
+// WithMaxBytes limits size of downloaded body.
+func WithMaxBytes(n int64) Option {
+	return func(s *impl) {
+		s.maxBytes = n
+	}
+}
+
+// WithMaxRedirects limits number of followed redirects.
+func WithMaxRedirects(n int) Option {
+	return func(s *impl) {
+		s.maxRedirects = n
+	}
+}
+
+// WithAllowedSchemes sets url schemes which can be downloaded.
+func WithAllowedSchemes(schemes ...string) Option {
+	return func(s *impl) {
+		s.allowedSchemes = schemes
+	}
+}
+
+// WithAllowedHosts restricts hosts which can be downloaded from,
+// host starting with "*." allows all its subdomains. All hosts are allowed by default.
+func WithAllowedHosts(hosts ...string) Option {
+	return func(s *impl) {
+		s.allowedHosts = hosts
+	}
+}
+
+// WithTimeouts sets timeouts of connecting and waiting for response headers.
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(s *impl) {
+		s.timeouts = timeouts
+	}
+}
+
+// WithRetries sets number of retries after transient failures and delay before the first one.
+func WithRetries(maxRetries int, delay time.Duration) Option {
+	return func(s *impl) {
+		s.maxRetries = maxRetries
+		s.retryDelay = delay
+	}
+}
+
+// WithAllowedContentTypes sets media types which can be downloaded, "type/*" allows all subtypes.
+func WithAllowedContentTypes(contentTypes ...string) Option {
+	return func(s *impl) {
+		s.allowedContentTypes = contentTypes
+	}
+}
+
+// WithBlockedNetworks replaces networks which can't be connected to.
+func WithBlockedNetworks(networks ...*net.IPNet) Option {
+	return func(s *impl) {
+		s.blockedNetworks = networks
+	}
+}
 
 // New returns downloader implementation.
-func New() Service {
-	return &impl{}
+func New(opts ...Option) Service {
+	s := &impl{
+		maxBytes:            DefaultMaxBytes,
+		maxRedirects:        DefaultMaxRedirects,
+		maxRetries:          DefaultMaxRetries,
+		retryDelay:          DefaultRetryDelay,
+		timeouts:            DefaultTimeouts,
+		allowedSchemes:      DefaultAllowedSchemes,
+		allowedContentTypes: DefaultAllowedContentTypes,
+		blockedNetworks:     DefaultBlockedNetworks,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	dialer := &net.Dialer{Timeout: s.timeouts.Dial, Control: CheckAddress(s.blockedNetworks)}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// proxy would make the dialer check proxy address instead of the target one.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = s.timeouts.TLSHandshake
+	transport.ResponseHeaderTimeout = s.timeouts.ResponseHeader
+
+	s.client = &http.Client{
+		Transport:     transport,
+		CheckRedirect: s.checkRedirect,
+	}
+	return s
 }
 
 // Download downloads file and returns response body from it.
 func (s *impl) Download(ctx context.Context, url string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	body, err := s.Open(ctx, url)
 	if err != nil {
 		return nil, err
 	}
+	defer body.Close()
 
-	req.WithContext(ctx)
-	client := http.DefaultClient
-	res, err := client.Do(req)
+	b, err := ioutil.ReadAll(body)
 	if err != nil {
+		return nil, fmt.Errorf("reading body for: %s failed with error: %w", url, err)
+	}
+
+	return b, nil
+}
+
+// Open requests file retrying transient failures and returns its body limited by maximal size.
+func (s *impl) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkURL(req.URL); err != nil {
 		return nil, err
 	}
 
-	defer res.Body.Close()
+	delay := s.retryDelay
+	for attempt := 0; ; attempt++ {
+		body, err := s.open(req)
+		if err == nil || attempt >= s.maxRetries || !isTransient(err) {
+			return body, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// statusError is returned when server responds with unexpected status code.
+type statusError struct {
+	url        string
+	statusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("error downloading %s, status code is: %d", e.url, e.statusCode)
+}
+
+func (s *impl) open(req *http.Request) (io.ReadCloser, error) {
+	url := req.URL.String()
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error downloading %s, status code is: %d", url, res.StatusCode)
+		res.Body.Close()
+		return nil, &statusError{url: url, statusCode: res.StatusCode}
+	}
+
+	if err := s.checkContentType(res.Header.Get("Content-Type")); err != nil {
+		res.Body.Close()
+		return nil, fmt.Errorf("%s: %w", url, err)
 	}
 
-	b, err := ioutil.ReadAll(res.Body)
+	if res.ContentLength > s.maxBytes {
+		res.Body.Close()
+		return nil, fmt.Errorf("%w: %s is %d bytes, at most %d bytes are allowed", ErrTooLarge, url, res.ContentLength, s.maxBytes)
+	}
+
+	return &limitedBody{body: res.Body, url: url, left: s.maxBytes}, nil
+}
+
+// checkContentType validates media type of the response, missing one is accepted as the body is decoded anyway.
+func (s *impl) checkContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		return nil, fmt.Errorf("reading body for: %s failed with error: %v", url, err)
+		return fmt.Errorf("%w: invalid content type '%s'", ErrUnsupportedContentType, contentType)
+	}
+	for _, allowed := range s.allowedContentTypes {
+		if mediaType == allowed || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, allowed[:len(allowed)-1])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: '%s'", ErrUnsupportedContentType, mediaType)
+}
+
+// isTransient reports whether request failed because of a temporary problem and can be repeated.
+func isTransient(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode >= http.StatusInternalServerError || statusErr.statusCode == http.StatusTooManyRequests
+	}
+	if errors.Is(err, ErrForbiddenURL) || errors.Is(err, ErrForbiddenAddress) || errors.Is(err, errTooManyRedirects) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// every *url.Error is a net.Error, so only timeouts and dropped connections are retried,
+	// failures of DNS, TLS or refused connections won't go away by repeating the request.
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
 	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
 
-	return b, nil
+// limitedBody fails with ErrTooLarge when more than left bytes are read.
+type limitedBody struct {
+	body io.ReadCloser
+	url  string
+	left int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left < 0 {
+		return 0, fmt.Errorf("%w: %s exceeds maximal size", ErrTooLarge, b.url)
+	}
+	// one extra byte is requested to find out whether the body is bigger than the limit.
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+	n, err := b.body.Read(p)
+	b.left -= int64(n)
+	if b.left < 0 {
+		return n + int(b.left), fmt.Errorf("%w: %s exceeds maximal size", ErrTooLarge, b.url)
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
+
+// checkURL validates scheme and host of the url against allowlists.
+func (s *impl) checkURL(u *url.URL) error {
+	if !containsString(s.allowedSchemes, strings.ToLower(u.Scheme)) {
+		return fmt.Errorf("%w: scheme '%s' is not allowed", ErrForbiddenURL, u.Scheme)
+	}
+	if len(s.allowedHosts) == 0 {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range s.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host '%s' is not allowed", ErrForbiddenURL, host)
+}
+
+// checkRedirect validates every redirect hop the same way as the initial url.
+func (s *impl) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > s.maxRedirects {
+		return fmt.Errorf("%w: stopped after %d redirects", errTooManyRedirects, s.maxRedirects)
+	}
+	return s.checkURL(req.URL)
+}
+
+// CheckAddress returns net.Dialer control function refusing connections to networks.
+// It's called with already resolved address right before connecting,
+// so hosts resolving to blocked networks can't be reached even through redirects or DNS rebinding.
+func CheckAddress(networks []*net.IPNet) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return fmt.Errorf("%w: invalid ip '%s'", ErrForbiddenAddress, host)
+		}
+		for _, n := range networks {
+			if n.Contains(ip) {
+				return fmt.Errorf("%w: %s belongs to blocked network %s", ErrForbiddenAddress, ip, n)
+			}
+		}
+		return nil
+	}
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCIDRs parses networks in CIDR notation for WithBlockedNetworks.
+func ParseCIDRs(cidrs ...string) ([]*net.IPNet, error) {
+	res := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	res, err := ParseCIDRs(cidrs...)
+	if err != nil {
+		panic(err)
+	}
+	return res
 }
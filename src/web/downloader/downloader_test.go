@@ -0,0 +1,338 @@
+package downloader
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDownloadMaxBytes(t *testing.T) {
+	type tc struct {
+		name        string
+		handler     http.HandlerFunc
+		expectedErr error
+	}
+
+	body := strings.Repeat("a", 100)
+
+	tcs := []tc{
+		{
+			name: "within limit",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				w.Write([]byte(body[:10]))
+			},
+		},
+		{
+			name: "content length exceeds limit",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				w.Write([]byte(body))
+			},
+			expectedErr: ErrTooLarge,
+		},
+		{
+			name: "streamed body exceeds limit",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.(http.Flusher).Flush()
+				w.Write([]byte(body))
+			},
+			expectedErr: ErrTooLarge,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(tc.handler)
+			defer srv.Close()
+
+			_, err := New(WithMaxBytes(50), WithBlockedNetworks()).Download(context.Background(), srv.URL)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected error is: %v but got: %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+// newServer starts test server listening on specific loopback ip.
+func newServer(t *testing.T, ip string, handler http.Handler) *httptest.Server {
+	l, err := net.Listen("tcp", ip+":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Listener.Close()
+	srv.Listener = l
+	srv.Start()
+	return srv
+}
+
+func redirectTo(url string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, url, http.StatusFound)
+	}
+}
+
+func TestDownloadRestrictions(t *testing.T) {
+	ok := newServer(t, "127.0.0.1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("ok"))
+	}))
+	defer ok.Close()
+
+	// blocked is reachable only through 127.0.0.2 which is blocked in tests.
+	blocked := newServer(t, "127.0.0.2", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer blocked.Close()
+
+	redirectToBlocked := newServer(t, "127.0.0.1", redirectTo(blocked.URL))
+	defer redirectToBlocked.Close()
+
+	redirectToOK := newServer(t, "127.0.0.1", redirectTo(ok.URL))
+	defer redirectToOK.Close()
+
+	redirectToRedirect := newServer(t, "127.0.0.1", redirectTo(redirectToOK.URL))
+	defer redirectToRedirect.Close()
+
+	redirectToLocalhost := newServer(t, "127.0.0.1", redirectTo(strings.Replace(ok.URL, "127.0.0.1", "localhost", 1)))
+	defer redirectToLocalhost.Close()
+
+	_, testNetwork, err := net.ParseCIDR("127.0.0.2/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type tc struct {
+		name        string
+		url         string
+		opts        []Option
+		expectedErr error
+	}
+
+	tcs := []tc{
+		{
+			name:        "loopback is blocked by default",
+			url:         ok.URL,
+			expectedErr: ErrForbiddenAddress,
+		},
+		{
+			name:        "blocked network",
+			url:         blocked.URL,
+			opts:        []Option{WithBlockedNetworks(testNetwork)},
+			expectedErr: ErrForbiddenAddress,
+		},
+		{
+			name: "allowed network",
+			url:  ok.URL,
+			opts: []Option{WithBlockedNetworks(testNetwork)},
+		},
+		{
+			name:        "scheme is not allowed",
+			url:         strings.Replace(ok.URL, "http://", "ftp://", 1),
+			opts:        []Option{WithBlockedNetworks(testNetwork)},
+			expectedErr: ErrForbiddenURL,
+		},
+		{
+			name:        "host is not allowed",
+			url:         ok.URL,
+			opts:        []Option{WithBlockedNetworks(testNetwork), WithAllowedHosts("example.com")},
+			expectedErr: ErrForbiddenURL,
+		},
+		{
+			name: "host is allowed",
+			url:  ok.URL,
+			opts: []Option{WithBlockedNetworks(testNetwork), WithAllowedHosts("example.com", "127.0.0.1")},
+		},
+		{
+			name:        "redirect to blocked network",
+			url:         redirectToBlocked.URL,
+			opts:        []Option{WithBlockedNetworks(testNetwork)},
+			expectedErr: ErrForbiddenAddress,
+		},
+		{
+			name:        "redirect to not allowed host",
+			url:         redirectToLocalhost.URL,
+			opts:        []Option{WithBlockedNetworks(testNetwork), WithAllowedHosts("127.0.0.1")},
+			expectedErr: ErrForbiddenURL,
+		},
+		{
+			name: "redirects within limit",
+			url:  redirectToRedirect.URL,
+			opts: []Option{WithBlockedNetworks(testNetwork), WithMaxRedirects(2)},
+		},
+		{
+			name:        "too many redirects",
+			url:         redirectToRedirect.URL,
+			opts:        []Option{WithBlockedNetworks(testNetwork), WithMaxRedirects(1)},
+			expectedErr: errTooManyRedirects,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := New(tc.opts...).Download(context.Background(), tc.url)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected error is: %v but got: %v", tc.expectedErr, err)
+			}
+			if tc.expectedErr == nil && string(b) != "ok" {
+				t.Fatalf("expected body is: ok but got: %s", b)
+			}
+		})
+	}
+}
+
+func TestDownloadRetries(t *testing.T) {
+	type tc struct {
+		name             string
+		failures         int
+		failureStatus    int
+		contentType      string
+		expectedAttempts int
+		expectedErr      bool
+	}
+
+	tcs := []tc{
+		{name: "success", expectedAttempts: 1, contentType: "image/jpeg"},
+		{name: "recovers after transient failures", failures: 2, failureStatus: http.StatusServiceUnavailable, contentType: "image/jpeg", expectedAttempts: 3},
+		{name: "gives up after max retries", failures: 3, failureStatus: http.StatusBadGateway, contentType: "image/jpeg", expectedAttempts: 3, expectedErr: true},
+		{name: "client errors aren't retried", failures: 1, failureStatus: http.StatusNotFound, contentType: "image/jpeg", expectedAttempts: 1, expectedErr: true},
+		{name: "unsupported content type", contentType: "text/html; charset=utf-8", expectedAttempts: 1, expectedErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts <= tc.failures {
+					w.WriteHeader(tc.failureStatus)
+					return
+				}
+				w.Header().Set("Content-Type", tc.contentType)
+				w.Write([]byte("ok"))
+			}))
+			defer srv.Close()
+
+			_, err := New(WithBlockedNetworks(), WithRetries(2, time.Millisecond)).Download(context.Background(), srv.URL)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error: %v but got: %v", tc.expectedErr, err)
+			}
+			if attempts != tc.expectedAttempts {
+				t.Fatalf("expected attempts: %d but got: %d", tc.expectedAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestTLSFailuresArentRetried(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	// the certificate of the test server isn't trusted by the default client.
+	_, err := New(WithBlockedNetworks(), WithRetries(2, time.Millisecond)).Download(context.Background(), srv.URL)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Fatalf("expected 1 connection but got: %d", n)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://images/a.jpg", Err: err}
+	}
+
+	type tc struct {
+		name     string
+		err      error
+		expected bool
+	}
+
+	tcs := []tc{
+		{name: "server error", err: &statusError{statusCode: http.StatusBadGateway}, expected: true},
+		{name: "client error", err: &statusError{statusCode: http.StatusNotFound}},
+		{name: "timeout", err: urlErr(&net.DNSError{Err: "i/o timeout", IsTimeout: true}), expected: true},
+		{name: "connection reset", err: urlErr(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), expected: true},
+		{name: "unexpected eof", err: urlErr(io.ErrUnexpectedEOF), expected: true},
+		{name: "unknown host", err: urlErr(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "images", IsNotFound: true}})},
+		{name: "untrusted certificate", err: urlErr(x509.UnknownAuthorityError{})},
+		{name: "connection refused", err: urlErr(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)})},
+		{name: "forbidden address", err: urlErr(fmt.Errorf("%w: 10.0.0.1", ErrForbiddenAddress))},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isTransient(tc.err); got != tc.expected {
+				t.Fatalf("expected transient: %v but got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer srv.Close()
+
+	body, err := New(WithBlockedNetworks()).Open(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil || len(b) != 100 {
+		t.Fatalf("expected to read 100 bytes, got: %d, error: %v", len(b), err)
+	}
+
+	body, err = New(WithBlockedNetworks(), WithMaxBytes(99)).Open(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	b, err = ioutil.ReadAll(body)
+	if !errors.Is(err, ErrTooLarge) || len(b) != 99 {
+		t.Fatalf("expected to read 99 bytes and get ErrTooLarge, got: %d, error: %v", len(b), err)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	timeouts := DefaultTimeouts
+	timeouts.ResponseHeader = 10 * time.Millisecond
+	_, err := New(WithBlockedNetworks(), WithTimeouts(timeouts), WithRetries(0, 0)).Download(context.Background(), srv.URL)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected timeout error but got: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+// Package recorder remembers what handlers wrote to responses, so middlewares can report it.
+package recorder
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ResponseWriter records status code and size of the response.
+type ResponseWriter struct {
+	http.ResponseWriter
+	Status int
+	Bytes  int64
+	// Route is a path template of the route matched by Route middleware, it's empty when none matched.
+	Route       string
+	wroteHeader bool
+}
+
+// New wraps w, status is 200 until handler writes another one.
+// Recorder of an outer middleware is reused, so all of them see the same route.
+func New(w http.ResponseWriter) *ResponseWriter {
+	if rec, ok := w.(*ResponseWriter); ok {
+		return rec
+	}
+	return &ResponseWriter{ResponseWriter: w, Status: http.StatusOK}
+}
+
+// Route records path template of the matched route. It's used by the router, since middlewares
+// wrapping the whole router run before routes are matched and mux runs its own ones only on matches.
+func Route(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rec, ok := w.(*ResponseWriter); ok {
+			if route := mux.CurrentRoute(r); route != nil {
+				if tmpl, err := route.GetPathTemplate(); err == nil {
+					rec.Route = tmpl
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WriteHeader records the first status code written.
+func (w *ResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.Status, w.wroteHeader = statusCode, true
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.Bytes += int64(n)
+	return n, err
+}
+
+// Flush keeps event streams working through the recorder.
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"path"
 
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/imager/src/logging"
+	"github.com/imager/src/model"
 )
 
 // Service describes uploader interface.
@@ -23,21 +26,24 @@ func New(s3manager *s3manager.Uploader, bucketName *string) Service {
 	return &impl{s3manager, bucketName}
 }
 
-// Upload uploads image to s3 bucket and returns link for download.
+// Upload uploads image to s3 bucket under prefix of tenant of ctx and returns link for download.
 func (s *impl) Upload(ctx context.Context, fileName string, r io.Reader) (string, error) {
 
 	var (
 		aclPerm = "public-read"
 	)
 
+	key := path.Join(model.TenantFromContext(ctx), fileName)
+
 	result, err := s.s3manager.UploadWithContext(ctx, &s3manager.UploadInput{
 		Bucket: s.bucketName,
-		Key:    &fileName,
+		Key:    &key,
 		Body:   r,
 		ACL:    &aclPerm,
 	})
 
 	if err != nil {
+		logging.FromContext(ctx).ErrorContext(ctx, "upload failed", "key", key, "error", err)
 		return "", fmt.Errorf("can't upload %s with error: %v", fileName, err)
 	}
 
@@ -0,0 +1,241 @@
+// Package webhooks sends image lifecycle events to subscribed urls.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/imager/src/model"
+	"github.com/imager/src/web/downloader"
+)
+
+const (
+	// SignatureHeader contains HMAC-SHA256 of the body keyed by secret of subscription, e.g. sha256=<hex>.
+	SignatureHeader = "X-Imager-Signature"
+	// EventHeader contains type of the event.
+	EventHeader = "X-Imager-Event"
+	// DeliveryHeader contains id of the delivery, it's the same for all attempts.
+	DeliveryHeader = "X-Imager-Delivery"
+
+	// DefaultMaxAttempts is a number of times delivery is sent before it's failed for good.
+	DefaultMaxAttempts = 8
+	// DefaultPollInterval is a time dispatcher waits for new deliveries when the outbox is empty.
+	DefaultPollInterval = time.Second
+	// DefaultTimeout limits time of sending one delivery.
+	DefaultTimeout = 10 * time.Second
+	// DefaultConcurrency is a number of deliveries sent at once.
+	DefaultConcurrency = 20
+
+	// batchSize is a number of deliveries claimed at once.
+	batchSize  = 20
+	minBackoff = 10 * time.Second
+	maxBackoff = time.Hour
+	// maxErrorBody is a number of bytes of response body kept as error of delivery.
+	maxErrorBody = 512
+)
+
+// Sign returns signature of body sent with SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateSecret returns new random secret of subscription.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating secret: %v", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Dispatcher sends deliveries from the outbox.
+type Dispatcher struct {
+	repo         model.WebhooksRepository
+	client       *http.Client
+	maxAttempts  int
+	pollInterval time.Duration
+	now          func() time.Time
+	// senders holds a token for every delivery being sent.
+	senders chan struct{}
+	sending sync.WaitGroup
+}
+
+// Option configures dispatcher.
+type Option func(*Dispatcher)
+
+// WithClient sets client deliveries are sent with.
+func WithClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithConcurrency sets number of deliveries sent at once, so a slow subscriber holds only one of them.
+func WithConcurrency(n int) Option {
+	return func(d *Dispatcher) {
+		d.senders = make(chan struct{}, n)
+	}
+}
+
+// NewDispatcher returns new dispatcher. By default subscribers in private networks can't be reached and redirects aren't followed.
+func NewDispatcher(repo model.WebhooksRepository, opts ...Option) *Dispatcher {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: downloader.CheckAddress(downloader.DefaultBlockedNetworks)}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	d := &Dispatcher{
+		repo: repo,
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		maxAttempts:  DefaultMaxAttempts,
+		pollInterval: DefaultPollInterval,
+		now:          time.Now,
+		senders:      make(chan struct{}, DefaultConcurrency),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run sends deliveries until ctx is done. Deliveries already claimed are sent before Run returns.
+func (d *Dispatcher) Run(ctx context.Context) {
+	defer d.sending.Wait()
+	for {
+		if d.runOnce(ctx) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.pollInterval):
+		}
+	}
+}
+
+// runOnce waits for a free sender, claims deliveries for all free senders and starts sending them,
+// it reports whether there were any.
+func (d *Dispatcher) runOnce(ctx context.Context) bool {
+	select {
+	case d.senders <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+	limit := 1
+	for limit < batchSize && d.tryAcquire() {
+		limit++
+	}
+
+	deliveries, err := d.repo.Claim(ctx, limit)
+	if err != nil {
+		slog.Error("error claiming deliveries", "error", err)
+		d.release(limit)
+		return false
+	}
+	d.release(limit - len(deliveries))
+
+	for _, delivery := range deliveries {
+		d.sending.Add(1)
+		go func(delivery model.PendingDelivery) {
+			defer d.sending.Done()
+			defer d.release(1)
+			d.deliver(delivery)
+		}(delivery)
+	}
+	return len(deliveries) > 0
+}
+
+// tryAcquire takes a sender when there is a free one.
+func (d *Dispatcher) tryAcquire() bool {
+	select {
+	case d.senders <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release frees n senders.
+func (d *Dispatcher) release(n int) {
+	for i := 0; i < n; i++ {
+		<-d.senders
+	}
+}
+
+// deliver sends delivery and stores its outcome.
+func (d *Dispatcher) deliver(delivery model.PendingDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	status, err := d.send(ctx, delivery)
+	if err == nil {
+		if err := d.repo.Delivered(ctx, delivery.ID, status); err != nil {
+			slog.Error("error marking delivery as delivered", "delivery_id", delivery.ID, "error", err)
+		}
+		return
+	}
+
+	var retryAt time.Time
+	if delivery.Attempts < d.maxAttempts {
+		retryAt = d.now().Add(backoff(delivery.Attempts))
+	}
+	if err := d.repo.Fail(ctx, delivery.ID, status, err.Error(), retryAt); err != nil {
+		slog.Error("error failing delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// send posts payload of delivery and returns response status, everything but 2xx is an error.
+func (d *Dispatcher) send(ctx context.Context, delivery model.PendingDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d: %s", resp.StatusCode, body)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns delay before next attempt of delivery which was sent attempts times.
+func backoff(attempts int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
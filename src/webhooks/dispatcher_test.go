@@ -0,0 +1,157 @@
+package webhooks
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mock_model "github.com/imager/src/mock/model"
+	"github.com/imager/src/model"
+)
+
+func TestRunOnce(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	const secret = "whsec_test"
+	payload := []byte(`{"Type":"image.created"}`)
+	now := time.Date(2020, 10, 25, 10, 0, 0, 0, time.UTC)
+
+	type tc struct {
+		name     string
+		status   int
+		attempts int
+		getRepo  func(*mock_model.MockWebhooksRepository)
+	}
+
+	tcs := []tc{
+		{
+			name:     "delivered",
+			status:   http.StatusOK,
+			attempts: 1,
+			getRepo: func(repo *mock_model.MockWebhooksRepository) {
+				repo.EXPECT().Delivered(gomock.Any(), 3, http.StatusOK).Return(nil)
+			},
+		},
+		{
+			name:     "retried with backoff",
+			status:   http.StatusInternalServerError,
+			attempts: 2,
+			getRepo: func(repo *mock_model.MockWebhooksRepository) {
+				repo.EXPECT().Fail(gomock.Any(), 3, http.StatusInternalServerError, gomock.Any(), now.Add(20*time.Second)).Return(nil)
+			},
+		},
+		{
+			name:     "failed after max attempts",
+			status:   http.StatusGone,
+			attempts: DefaultMaxAttempts,
+			getRepo: func(repo *mock_model.MockWebhooksRepository) {
+				repo.EXPECT().Fail(gomock.Any(), 3, http.StatusGone, gomock.Any(), time.Time{}).Return(nil)
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if signature := r.Header.Get(SignatureHeader); signature != Sign(secret, body) {
+					t.Fatalf("unexpected signature: %s", signature)
+				}
+				if event := r.Header.Get(EventHeader); event != model.EventImageCreated {
+					t.Fatalf("unexpected event: %s", event)
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			repo := mock_model.NewMockWebhooksRepository(mockCtrl)
+			repo.EXPECT().Claim(gomock.Any(), batchSize).Return([]model.PendingDelivery{{
+				Delivery: model.Delivery{ID: 3, Event: model.EventImageCreated, Payload: payload, Attempts: tc.attempts},
+				URL:      srv.URL,
+				Secret:   secret,
+			}}, nil)
+			tc.getRepo(repo)
+
+			d := NewDispatcher(repo, WithClient(srv.Client()))
+			d.now = func() time.Time { return now }
+			if !d.runOnce(context.Background()) {
+				t.Fatal("expected deliveries to be sent")
+			}
+			d.sending.Wait()
+		})
+	}
+}
+
+func TestSlowSubscriberDoesNotBlockOthers(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	unblock := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fast.Close()
+
+	delivered := make(chan struct{})
+	repo := mock_model.NewMockWebhooksRepository(mockCtrl)
+	repo.EXPECT().Claim(gomock.Any(), 2).Return([]model.PendingDelivery{
+		{Delivery: model.Delivery{ID: 1, Attempts: 1}, URL: slow.URL},
+		{Delivery: model.Delivery{ID: 2, Attempts: 1}, URL: fast.URL},
+	}, nil)
+	repo.EXPECT().Delivered(gomock.Any(), 2, http.StatusOK).DoAndReturn(func(context.Context, int, int) error {
+		close(delivered)
+		return nil
+	})
+	repo.EXPECT().Delivered(gomock.Any(), 1, http.StatusOK).Return(nil)
+
+	d := NewDispatcher(repo, WithClient(http.DefaultClient), WithConcurrency(2))
+	if !d.runOnce(context.Background()) {
+		t.Fatal("expected deliveries to be sent")
+	}
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery to fast subscriber waited for slow one")
+	}
+	close(unblock)
+	d.sending.Wait()
+}
+
+func TestDefaultClientBlocksPrivateNetworks(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("request to loopback address shouldn't be sent")
+	}))
+	defer srv.Close()
+
+	repo := mock_model.NewMockWebhooksRepository(mockCtrl)
+	repo.EXPECT().Claim(gomock.Any(), batchSize).Return([]model.PendingDelivery{{
+		Delivery: model.Delivery{ID: 3, Event: model.EventImageCreated, Payload: []byte("{}"), Attempts: 1},
+		URL:      srv.URL,
+	}}, nil)
+	repo.EXPECT().Fail(gomock.Any(), 3, 0, gomock.Any(), gomock.Any()).Return(nil)
+
+	d := NewDispatcher(repo)
+	d.runOnce(context.Background())
+	d.sending.Wait()
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{}' | openssl dgst -sha256 -hmac secret
+	const expected = "sha256=77325902caca812dc259733aacd046b73817372c777b8d95b402647474516e13"
+	if signature := Sign("secret", []byte("{}")); signature != expected {
+		t.Fatalf("expected signature is: %s but got: %s", expected, signature)
+	}
+}